@@ -4,15 +4,30 @@
 package frontend
 
 import (
+    "encoding/binary"
     "flag"
     "fmt"
     "github.com/kierdavis/gosound/sound"
+    "github.com/kierdavis/gosound/sound/meter"
     "github.com/kierdavis/gosound/soundio"
+    "github.com/kierdavis/gosound/soundio/aiffio"
     "github.com/kierdavis/gosound/soundio/alsaio"
+    "github.com/kierdavis/gosound/soundio/auio"
+    "github.com/kierdavis/gosound/soundio/flacio"
+    "github.com/kierdavis/gosound/soundio/memio"
+    "github.com/kierdavis/gosound/soundio/multiio"
+    "github.com/kierdavis/gosound/soundio/rawio"
     "github.com/kierdavis/gosound/soundio/sndfileio"
+    "github.com/kierdavis/gosound/soundio/wavio"
     "github.com/mkb218/gosndfile/sndfile"
+    "io"
+    "math"
     "os"
+    "path/filepath"
     "runtime"
+    "strconv"
+    "strings"
+    "sync"
     "time"
 )
 
@@ -21,55 +36,503 @@ var (
     OutputFile string
     Format string
     NumThreads int
+    Loudness bool
+    Normalise string
+    Target float64
+    Bits int
+    Float bool
+    Endian string
+    Lengths string
+    DitherType string
+    Null bool
+    Stems string
+    SplitLength time.Duration
+    SplitSilence bool
+    SilenceThreshold float64
+    MinSilence time.Duration
+    ManifestFile string
+    CueFile string
+    Title string
+    Artist string
+    Comment string
+    BPM float64
+    Key string
 )
 
 // flag setup
 func init() {
-    flag.StringVar(&OutputFile, "output", "", "filename to write output to; if not specified, generated audio is played instead")
-    flag.StringVar(&Format, "format", "wav", "output format (available: 'aiff', 'au', 'flac', 'ogg', 'wav')")
+    flag.StringVar(&OutputFile, "output", "", "filename to write output to, or '-' for standard output; if not specified, generated audio is played instead")
+    flag.StringVar(&Format, "format", "wav", "output format (available: 'aiff', 'au', 'flac', 'ogg', 'raw', 'wav')")
     flag.IntVar(&NumThreads, "threads", 1, "maximum number of parallel tasks")
+    flag.BoolVar(&Loudness, "loudness", false, "print a loudness summary (EBU R128) after rendering")
+    flag.StringVar(&Normalise, "normalise", "", "when writing to a file, render to memory first and apply gain so that the measured level hits -target (available: 'peak', 'truepeak', 'loudness')")
+    flag.Float64Var(&Target, "target", -14.0, "target level for -normalise, in dBFS, dBTP or LUFS")
+    flag.IntVar(&Bits, "bits", 16, "sample size of the output in bits (available: 8, 16, 24, 32; or 32, 64 with -float)")
+    flag.BoolVar(&Float, "float", false, "write floating point samples instead of integers")
+    flag.StringVar(&Lengths, "lengths", "pad", "how to write channels that end at different times (available: 'pad' with silence, 'truncate' to the shortest, 'require' equal lengths and fail otherwise)")
+    flag.StringVar(&Endian, "endian", "little", "byte order of raw output (available: 'little', 'big')")
+    flag.StringVar(&DitherType, "dither", "tpdf", "dither to apply when writing integer samples (available: 'none', 'tpdf', 'shaped')")
+    flag.BoolVar(&Null, "null", false, "discard generated audio instead of playing or writing it, to measure rendering speed")
+    flag.StringVar(&Stems, "stems", "", "write groups of channels to separate files named after -output (available: 'each' for one file per channel, or a list such as 'drums=0,1;bass=2')")
+    flag.DurationVar(&SplitLength, "split", 0, "split the output into numbered files of at most this length")
+    flag.BoolVar(&SplitSilence, "split-silence", false, "split the output into numbered files wherever it is silent for -min-silence, leaving out the silence")
+    flag.Float64Var(&SilenceThreshold, "silence-threshold", -60.0, "level in dBFS below which output counts as silence for -split-silence")
+    flag.DurationVar(&MinSilence, "min-silence", 2*time.Second, "shortest gap that separates files for -split-silence")
+    flag.StringVar(&ManifestFile, "manifest", "", "when writing several files, write a JSON description of them to this file")
+    flag.StringVar(&CueFile, "cue", "", "when writing several files, write a cue sheet listing them to this file")
+    flag.StringVar(&Title, "title", "", "title to record in the output file")
+    flag.StringVar(&Artist, "artist", "", "artist to record in the output file")
+    flag.StringVar(&Comment, "comment", "", "comment to record in the output file")
+    flag.Float64Var(&BPM, "bpm", 0, "tempo in beats per minute to record in the output file")
+    flag.StringVar(&Key, "key", "", "key to record in the output file (such as 'C', 'F#m' or 'Bb minor')")
 }
 
-func getOutput(ctx sound.Context) (so soundio.SoundOutput) {
-    if OutputFile == "" {
-        return alsaio.DefaultOutput
+// Metadata is recorded in the output file, if the format supports it (WAV,
+// AIFF and FLAC). It can be set before calling Main, and the -title, -artist,
+// -comment, -bpm and -key flags override its fields.
+var Metadata soundio.Metadata
+
+// SetSequencer records the tempo and key of a sequencer in Metadata.
+func SetSequencer(seq *sound.Sequencer) {
+    Metadata.BPM = seq.Tempo
+    Metadata.Key = seq.Key
+}
+
+// Apply the metadata flags to Metadata.
+func parseMetadata() {
+    if Title != "" {
+        Metadata.Title = Title
+    }
+    if Artist != "" {
+        Metadata.Artist = Artist
+    }
+    if Comment != "" {
+        Metadata.Comment = Comment
+    }
+    if BPM != 0 {
+        Metadata.BPM = BPM
+    }
+    if Key != "" {
+        key, err := soundio.ParseKey(Key)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Bad key: %s\n", Key)
+            os.Exit(1)
+        }
+        Metadata.Key = &key
+    }
+}
+
+// Destination of status messages. This is standard error when the audio
+// itself is written to standard output.
+var messages io.Writer = os.Stdout
+
+// Get the handling of channels of different lengths selected on the command
+// line.
+func getLengthPolicy() soundio.LengthPolicy {
+    switch Lengths {
+    case "pad":
+        return soundio.PadShorter
+    case "truncate":
+        return soundio.TruncateToShortest
+    case "require":
+        return soundio.RequireEqualLength
+    }
+    
+    fmt.Fprintf(os.Stderr, "Bad length policy: %s\n", Lengths)
+    os.Exit(1)
+    return 0
+}
+
+// Get the byte order of raw output selected on the command line.
+func getByteOrder() binary.ByteOrder {
+    switch Endian {
+    case "little":
+        return binary.LittleEndian
+    case "big":
+        return binary.BigEndian
+    }
     
+    fmt.Fprintf(os.Stderr, "Bad byte order: %s\n", Endian)
+    os.Exit(1)
+    return nil
+}
+
+// Get the sample format and dither selected on the command line.
+func getSampleFormat() (format soundio.SampleFormat, dither soundio.Dither) {
+    var ok bool
+    if Float {
+        format, ok = map[int]soundio.SampleFormat{32: soundio.Float32, 64: soundio.Float64}[Bits]
     } else {
-        var formatCode sndfile.Format
-        
+        format, ok = map[int]soundio.SampleFormat{8: soundio.Int8, 16: soundio.Int16, 24: soundio.Int24, 32: soundio.Int32}[Bits]
+    }
+    if !ok {
+        fmt.Fprintf(os.Stderr, "Bad bit depth: %d\n", Bits)
+        os.Exit(1)
+    }
+    
+    switch DitherType {
+    case "none":
+        dither = soundio.NoDither
+    case "tpdf":
+        dither = soundio.TPDF
+    case "shaped":
+        dither = soundio.ShapedTPDF
+    default:
+        fmt.Fprintf(os.Stderr, "Bad dither: %s\n", DitherType)
+        os.Exit(1)
+    }
+    
+    return format, dither
+}
+
+func getOutput(ctx sound.Context, numChannels int) (so soundio.SoundOutput) {
+    sampleFormat, dither := getSampleFormat()
+    multi := Stems != "" || SplitLength > 0 || SplitSilence
+    
+    if Null {
+        return &memio.NullOutput{
+            BufferSize: ctx.StreamBufferSize,
+            LengthPolicy: getLengthPolicy(),
+        }
+    
+    } else if multi && (OutputFile == "" || OutputFile == "-") {
+        fmt.Fprintf(os.Stderr, "Several files cannot be written without an output filename\n")
+        os.Exit(1)
+        return nil
+    
+    } else if OutputFile == "" {
+        output := alsaio.DefaultOutput
+        output.Format = sampleFormat
+        output.Dither = dither
+        output.LengthPolicy = getLengthPolicy()
+        return output
+    
+    } else if OutputFile == "-" {
         switch Format {
-        case "aiff":
-            formatCode = sndfile.SF_FORMAT_AIFF | sndfile.SF_FORMAT_PCM_16
-        case "au":
-            formatCode = sndfile.SF_FORMAT_AU | sndfile.SF_FORMAT_PCM_16
-        case "flac":
-            formatCode = sndfile.SF_FORMAT_FLAC | sndfile.SF_FORMAT_PCM_16
-        case "ogg":
-            formatCode = sndfile.SF_FORMAT_OGG | sndfile.SF_FORMAT_VORBIS
+        case "raw":
+            return rawio.Output{
+                Writer: os.Stdout,
+                Format: sampleFormat,
+                ByteOrder: getByteOrder(),
+                BufferSize: ctx.StreamBufferSize,
+                Dither: dither,
+                LengthPolicy: getLengthPolicy(),
+            }
         case "wav":
-            formatCode = sndfile.SF_FORMAT_WAV | sndfile.SF_FORMAT_PCM_16
+            return wavio.StreamOutput{
+                Writer: os.Stdout,
+                Format: sampleFormat,
+                BufferSize: ctx.StreamBufferSize,
+                Dither: dither,
+                LengthPolicy: getLengthPolicy(),
+                Metadata: Metadata,
+            }
         default:
-            fmt.Fprintf(os.Stderr, "Bad format: %s\n", Format)
+            fmt.Fprintf(os.Stderr, "Format cannot be written to standard output: %s\n", Format)
             os.Exit(1)
         }
-        
-        return sndfileio.SndFileOutput{
-            Filename: OutputFile,
-            Format: formatCode,
+        return nil
+    
+    } else if multi {
+        return getMultiOutput(ctx, numChannels)
+    
+    } else {
+        return getFileOutput(ctx, OutputFile, Metadata)
+    }
+}
+
+// Get the output that writes a single file in the selected format.
+func getFileOutput(ctx sound.Context, filename string, md soundio.Metadata) (so soundio.SoundOutput) {
+    sampleFormat, dither := getSampleFormat()
+    var formatCode sndfile.Format
+    
+    switch Format {
+    case "aiff":
+        return aiffio.Output{
+            Filename: filename,
+            Format: sampleFormat,
+            BufferSize: ctx.StreamBufferSize,
+            Dither: dither,
+            LengthPolicy: getLengthPolicy(),
+            Metadata: md,
+        }
+    case "au":
+        return auio.Output{
+            Filename: filename,
+            Encoding: auEncodings[sampleFormat],
+            BufferSize: ctx.StreamBufferSize,
+            Dither: dither,
+            LengthPolicy: getLengthPolicy(),
+        }
+    case "flac":
+        if Float || Bits > 24 {
+            fmt.Fprintf(os.Stderr, "Bad bit depth for FLAC: %d\n", Bits)
+            os.Exit(1)
+        }
+        return flacio.Output{
+            Filename: filename,
             BufferSize: ctx.StreamBufferSize,
+            BitsPerSample: Bits,
+            Dither: dither,
+            LengthPolicy: getLengthPolicy(),
+            Level: flacio.DefaultLevel,
+            SeekPoints: 100,
+            Metadata: md,
+        }
+    case "ogg":
+        formatCode = sndfile.SF_FORMAT_OGG | sndfile.SF_FORMAT_VORBIS
+    case "raw":
+        return rawio.Output{
+            Filename: filename,
+            Format: sampleFormat,
+            ByteOrder: getByteOrder(),
+            BufferSize: ctx.StreamBufferSize,
+            Dither: dither,
+            LengthPolicy: getLengthPolicy(),
+        }
+    case "wav":
+        return wavio.Output{
+            Filename: filename,
+            Format: sampleFormat,
+            BufferSize: ctx.StreamBufferSize,
+            Dither: dither,
+            LengthPolicy: getLengthPolicy(),
+            Metadata: md,
+        }
+    default:
+        fmt.Fprintf(os.Stderr, "Bad format: %s\n", Format)
+        os.Exit(1)
+    }
+    
+    return sndfileio.SndFileOutput{
+        Filename: filename,
+        Format: formatCode,
+        BufferSize: ctx.StreamBufferSize,
+        LengthPolicy: getLengthPolicy(),
+    }
+}
+
+// Get the output that writes stems or split files, named by inserting a suffix
+// before the extension of the output filename.
+func getMultiOutput(ctx sound.Context, numChannels int) (so soundio.SoundOutput) {
+    md := Metadata
+    newOutput := func(filename string) soundio.SoundOutput {
+        return getFileOutput(ctx, filename, md)
+    }
+    
+    if SplitLength > 0 || SplitSilence {
+        // Positions in the render do not apply to the pieces.
+        md.Loops = nil
+        md.Markers = nil
+        
+        fileOutput := newOutput
+        newOutput = func(filename string) soundio.SoundOutput {
+            splitter := &multiio.Splitter{
+                Pattern: filenamePattern(filename, "-%03d"),
+                NewOutput: fileOutput,
+                BufferSize: ctx.StreamBufferSize,
+                LengthPolicy: getLengthPolicy(),
+                Length: SplitLength,
+                Threshold: SilenceThreshold,
+            }
+            if SplitSilence {
+                splitter.MinSilence = MinSilence
+            }
+            return splitter
+        }
+    }
+    
+    if Stems == "" {
+        return newOutput(OutputFile)
+    }
+    
+    var stems []multiio.Stem
+    if Stems == "each" {
+        stems = multiio.EachChannel(filenamePattern(OutputFile, "-%d"), numChannels)
+    
+    } else {
+        ext := filepath.Ext(OutputFile)
+        for _, group := range strings.Split(Stems, ";") {
+            parts := strings.SplitN(group, "=", 2)
+            if len(parts) != 2 || parts[0] == "" {
+                fmt.Fprintf(os.Stderr, "Bad stem: %s\n", group)
+                os.Exit(1)
+            }
+            
+            stem := multiio.Stem{
+                Filename: strings.TrimSuffix(OutputFile, ext) + "-" + parts[0] + ext,
+            }
+            for _, field := range strings.Split(parts[1], ",") {
+                c, err := strconv.Atoi(strings.TrimSpace(field))
+                if err != nil || c < 0 || c >= numChannels {
+                    fmt.Fprintf(os.Stderr, "Bad channel in stem %s: %s\n", parts[0], field)
+                    os.Exit(1)
+                }
+                stem.Channels = append(stem.Channels, c)
+            }
+            stems = append(stems, stem)
+        }
+    }
+    
+    return &multiio.Stems{
+        Stems: stems,
+        NewOutput: newOutput,
+        BufferSize: ctx.StreamBufferSize,
+    }
+}
+
+// Make a format string from a filename by inserting 'verb' before its
+// extension, escaping any percent signs already in the name.
+func filenamePattern(filename string, verb string) string {
+    ext := filepath.Ext(filename)
+    base := strings.Replace(strings.TrimSuffix(filename, ext), "%", "%%", -1)
+    return base + verb + strings.Replace(ext, "%", "%%", -1)
+}
+
+// Write the manifest and cue sheet requested on the command line.
+func writeManifests(sampleRate float64, pieces []multiio.Piece) (err error) {
+    if ManifestFile != "" {
+        f, err := os.Create(ManifestFile)
+        if err != nil {
+            return err
+        }
+        err = multiio.WriteManifest(f, sampleRate, pieces)
+        f.Close()
+        if err != nil {
+            return err
+        }
+    }
+    
+    if CueFile != "" {
+        f, err := os.Create(CueFile)
+        if err != nil {
+            return err
+        }
+        err = multiio.WriteCueSheet(f, pieces)
+        f.Close()
+        if err != nil {
+            return err
         }
     }
+    
+    return nil
+}
+
+var auEncodings = map[soundio.SampleFormat]auio.Encoding{
+    soundio.Int8: auio.Linear8,
+    soundio.Int16: auio.Linear16,
+    soundio.Int24: auio.Linear24,
+    soundio.Int32: auio.Linear32,
+    soundio.Float32: auio.Float,
+    soundio.Float64: auio.Double,
+}
+
+// Render the channels into memory, measure them and return streams that replay
+// them with the gain required to meet the target level.
+func normalise(ctx sound.Context, channels []chan float64) (outputs []chan float64) {
+    buffers := make([][]float64, len(channels))
+    
+    // Every channel must be read concurrently, since they may share upstream
+    // forks.
+    var wg sync.WaitGroup
+    for i, channel := range channels {
+        wg.Add(1)
+        go func(i int, channel chan float64) {
+            buffers[i] = ctx.ToBuffer(channel)
+            wg.Done()
+        }(i, channel)
+    }
+    wg.Wait()
+    
+    streams := make([]chan float64, len(buffers))
+    for i, buffer := range buffers {
+        streams[i] = ctx.FromBuffer(buffer)
+    }
+    
+    var level, truePeak float64
+    switch Normalise {
+    case "peak", "truepeak":
+        level = math.Inf(-1)
+        for _, stream := range streams {
+            var output, report chan float64
+            if Normalise == "peak" {
+                output, report = meter.Peak(ctx, stream)
+            } else {
+                output, report = meter.TruePeak(ctx, stream)
+            }
+            ctx.Drain(output)
+            level = math.Max(level, meter.ToDecibels(<-report))
+        }
+    
+    case "loudness":
+        var report chan meter.LoudnessReport
+        streams, report = meter.Loudness(ctx, streams, nil)
+        for _, stream := range streams {
+            ctx.Drain(stream)
+        }
+        r := <-report
+        level, truePeak = r.Integrated, r.TruePeak
+    }
+    
+    gain := 1.0
+    if math.IsInf(level, -1) {
+        fmt.Fprintf(messages, "Output is silent; not normalising.\n")
+    } else {
+        gain = meter.FromDecibels(Target - level)
+        fmt.Fprintf(messages, "Measured level %.1f, applying gain of %+.1f dB.\n", level, Target-level)
+        
+        // Raising the loudness can push the peaks over full scale.
+        if Normalise == "loudness" && truePeak+Target-level > 0 {
+            fmt.Fprintf(messages, "Warning: true peak will be %+.1f dBTP; the output may clip.\n", truePeak+Target-level)
+        }
+    }
+    
+    outputs = make([]chan float64, len(buffers))
+    for i, buffer := range buffers {
+        outputs[i] = ctx.MulInf(ctx.FromBuffer(buffer), ctx.Const(gain))
+    }
+    
+    return outputs
 }
 
 func Main(ctx sound.Context, channels... chan float64) {
     flag.Parse()
+    parseMetadata()
     runtime.GOMAXPROCS(NumThreads)
     
+    switch Normalise {
+    case "", "peak", "truepeak", "loudness":
+    default:
+        fmt.Fprintf(os.Stderr, "Bad normalisation mode: %s\n", Normalise)
+        os.Exit(1)
+    }
+    
+    if Normalise != "" && OutputFile == "" {
+        fmt.Fprintf(os.Stderr, "Normalisation requires -output\n")
+        os.Exit(1)
+    }
+    
     // Make a copy of the argument array before we modify it.
     channels2 := make([]chan float64, len(channels))
     copy(channels2, channels)
     channels = channels2
     
+    if OutputFile == "-" {
+        messages = os.Stderr
+    }
+    
+    startTime := time.Now()
+    
+    if Normalise != "" {
+        channels = normalise(ctx, channels)
+    }
+    
+    // Meter the loudness of the output
+    var loudnessChan chan meter.LoudnessReport
+    if Loudness {
+        channels, loudnessChan = meter.Loudness(ctx, channels, nil)
+    }
+    
     // Measure the duration of the first channel
     var durationStream chan float64
     channels[0], durationStream = ctx.Fork2(channels[0])
@@ -78,18 +541,41 @@ func Main(ctx sound.Context, channels... chan float64) {
         durationChan <- ctx.Duration(durationStream)
     }()
     
-    so := getOutput(ctx)
+    so := getOutput(ctx, len(channels))
     
     // Write the output
-    startTime := time.Now()
     err := so.Write(ctx.SampleRate, channels)
     endTime := time.Now()
     
     if err != nil {
-        fmt.Printf("Error: %s\n", err.Error())
+        fmt.Fprintf(messages, "Error: %s\n", err.Error())
+        
+        // The output may have stopped reading early; drain the rest so that
+        // the meters and the duration measurement can finish.
+        for _, channel := range channels {
+            ctx.Drain(channel)
+        }
     }
     
     outSecs := float64(<-durationChan) / float64(time.Second)
     realSecs := float64(endTime.Sub(startTime)) / float64(time.Second)
-    fmt.Printf("Generated %.3f seconds of audio in %.3f seconds (ratio %.3f).\n", outSecs, realSecs, outSecs/realSecs)
+    fmt.Fprintf(messages, "Generated %.3f seconds of audio in %.3f seconds (ratio %.3f).\n", outSecs, realSecs, outSecs/realSecs)
+    
+    if multi, ok := so.(multiio.MultiOutput); ok && err == nil {
+        pieces := multi.Files()
+        fmt.Fprintf(messages, "Wrote %d files.\n", len(pieces))
+        
+        if err := writeManifests(ctx.SampleRate, pieces); err != nil {
+            fmt.Fprintf(messages, "Error: %s\n", err.Error())
+        }
+    }
+    
+    if null, ok := so.(*memio.NullOutput); ok {
+        fmt.Fprintf(messages, "Discarded %d frames at %.0f frames per second.\n", null.Frames, null.Throughput())
+    }
+    
+    if loudnessChan != nil && err == nil {
+        r := <-loudnessChan
+        fmt.Fprintf(messages, "Loudness: %.1f LUFS integrated, %.1f LU range, %.1f LUFS max short-term, %.1f dBTP true peak.\n", r.Integrated, r.Range, r.MaxShortTerm, r.TruePeak)
+    }
 }
@@ -0,0 +1,92 @@
+package music
+
+// ChordQuality identifies the intervals of a chord above its root.
+type ChordQuality int
+
+const (
+	NoChord ChordQuality = iota
+	MajorTriad
+	MinorTriad
+	DiminishedTriad
+	AugmentedTriad
+	DominantSeventh
+	MajorSeventh
+	MinorSeventh
+)
+
+// Intervals returns the number of semitones above the root of each note of a
+// chord of this quality, including the root itself.
+func (q ChordQuality) Intervals() (intervals []int) {
+	switch q {
+	case MajorTriad:
+		return []int{0, 4, 7}
+	case MinorTriad:
+		return []int{0, 3, 7}
+	case DiminishedTriad:
+		return []int{0, 3, 6}
+	case AugmentedTriad:
+		return []int{0, 4, 8}
+	case DominantSeventh:
+		return []int{0, 4, 7, 10}
+	case MajorSeventh:
+		return []int{0, 4, 7, 11}
+	case MinorSeventh:
+		return []int{0, 3, 7, 10}
+	}
+
+	return nil
+}
+
+// Suffix returns the symbol appended to the root in chord names.
+func (q ChordQuality) Suffix() string {
+	switch q {
+	case MajorTriad:
+		return ""
+	case MinorTriad:
+		return "m"
+	case DiminishedTriad:
+		return "dim"
+	case AugmentedTriad:
+		return "aug"
+	case DominantSeventh:
+		return "7"
+	case MajorSeventh:
+		return "maj7"
+	case MinorSeventh:
+		return "m7"
+	}
+
+	return ""
+}
+
+// A Chord is a set of pitch classes built on a root. The zero value is
+// NoChord, used for silence or unrecognisable harmony.
+type Chord struct {
+	Root    NoteLetter
+	Quality ChordQuality
+}
+
+// Letters returns the pitch classes of the chord, starting with the root.
+func (c Chord) Letters() (letters []NoteLetter) {
+	for _, i := range c.Quality.Intervals() {
+		letters = append(letters, NoteLetter((int(c.Root)+i)%12))
+	}
+	return letters
+}
+
+// Notes returns the notes of the chord in root position, with the root in the
+// given octave.
+func (c Chord) Notes(octave int) (notes []Note) {
+	root := MakeNote(c.Root, octave)
+	for _, i := range c.Quality.Intervals() {
+		notes = append(notes, root.Add(i))
+	}
+	return notes
+}
+
+func (c Chord) String() string {
+	if c.Quality == NoChord {
+		return "N"
+	}
+	return c.Root.String() + c.Quality.Suffix()
+}
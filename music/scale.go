@@ -26,3 +26,44 @@ func (s *Scale) Prev(n int) {
 		s.Root = s.Root.Add(-s.Intervals[s.CurrentInterval])
 	}
 }
+
+// Mode returns the name of the scale's intervals if they are one of those
+// defined in this package, or the empty string.
+func (s Scale) Mode() string {
+	modes := []struct {
+		name      string
+		intervals []int
+	}{
+		{"major", Major},
+		{"minor", Minor},
+		{"harmonic minor", HarmonicMinor},
+		{"melodic minor", MelodicMinor},
+	}
+
+	for _, mode := range modes {
+		if len(mode.intervals) != len(s.Intervals) {
+			continue
+		}
+
+		match := true
+		for i, x := range mode.intervals {
+			if s.Intervals[i] != x {
+				match = false
+				break
+			}
+		}
+		if match {
+			return mode.name
+		}
+	}
+
+	return ""
+}
+
+func (s Scale) String() string {
+	mode := s.Mode()
+	if mode == "" {
+		return s.Root.Letter().String()
+	}
+	return s.Root.Letter().String() + " " + mode
+}
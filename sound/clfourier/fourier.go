@@ -0,0 +1,18 @@
+package clfourier
+
+import (
+	"github.com/kierdavis/gosound/sound/fourier"
+)
+
+// NewAuto returns a CLFourier if an OpenCL device is available, or a
+// fourier.CPUFourier otherwise.
+func NewAuto(logInputSize uint, numFreqs int) (f fourier.Fourier) {
+	device, err := GetDefaultDevice()
+	if err != nil {
+		return fourier.NewCPU(logInputSize, numFreqs)
+	}
+
+	c := New(logInputSize, numFreqs)
+	c.Device = device
+	return c
+}
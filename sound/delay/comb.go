@@ -0,0 +1,85 @@
+package delay
+
+import (
+	"github.com/kierdavis/gosound/sound"
+)
+
+// The filters in this file produce exactly as many values as they receive. Pad
+// the input (see sound.Context.Pad) to hear the decaying tail. They panic if
+// 'length' is zero.
+
+// Panic if the filter 'name' is given a zero delay length, for which there is
+// no delayed value to read.
+func checkLength(name string, length uint) {
+	if length == 0 {
+		panic(name + ": length must be at least 1")
+	}
+}
+
+// FeedforwardComb computes y[n] = x[n] + g*x[n-D], where D is 'length' and g
+// is received from 'gainInput'.
+func FeedforwardComb(ctx sound.Context, input chan float64, length uint, gainInput chan float64) (output chan float64) {
+	checkLength("FeedforwardComb", length)
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		line := NewLine(int(length))
+
+		for x := range input {
+			output <- x + (<-gainInput)*line.At(int(length)-1)
+			line.Push(x)
+		}
+	}()
+
+	return output
+}
+
+// FeedbackComb computes y[n] = x[n] + g*y[n-D], where D is 'length' and g is
+// received from 'gainInput'. The gain should be less than 1 in magnitude for
+// the filter to be stable.
+func FeedbackComb(ctx sound.Context, input chan float64, length uint, gainInput chan float64) (output chan float64) {
+	checkLength("FeedbackComb", length)
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		line := NewLine(int(length))
+
+		for x := range input {
+			y := x + (<-gainInput)*line.At(int(length)-1)
+			output <- y
+			line.Push(y)
+		}
+	}()
+
+	return output
+}
+
+// AllPass is a Schroeder all-pass section, computing
+// y[n] = -g*x[n] + x[n-D] + g*y[n-D], where D is 'length' and g is received
+// from 'gainInput'. It has a flat magnitude response but smears the phase,
+// which makes it a useful building block for reverbs and phasers.
+func AllPass(ctx sound.Context, input chan float64, length uint, gainInput chan float64) (output chan float64) {
+	checkLength("AllPass", length)
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		// Direct form II: a single line holds v[n] = x[n] + g*v[n-D].
+		line := NewLine(int(length))
+
+		for x := range input {
+			g := <-gainInput
+			delayed := line.At(int(length) - 1)
+			v := x + g*delayed
+			output <- -g*v + delayed
+			line.Push(v)
+		}
+	}()
+
+	return output
+}
@@ -0,0 +1,124 @@
+package delay
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/sound"
+	"math"
+)
+
+// Fixed delays 'input' by 'length' samples. Each output value is produced
+// before the corresponding input value is received, so it can be used to close
+// a feedback loop without deadlocking, however little buffering the loop has.
+// The output is 'length' samples longer than the input.
+func Fixed(ctx sound.Context, input chan float64, length uint) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		if length == 0 {
+			for x := range input {
+				output <- x
+			}
+			return
+		}
+
+		ring := make([]float64, length)
+		pos := 0
+
+		for {
+			output <- ring[pos]
+
+			x, ok := <-input
+			if !ok {
+				break
+			}
+			ring[pos] = x
+			pos = (pos + 1) % len(ring)
+		}
+
+		// Flush the values still in the line; the oldest was sent above.
+		for i := 1; i < len(ring); i++ {
+			output <- ring[(pos+i)%len(ring)]
+		}
+	}()
+
+	return output
+}
+
+// Fractional delays 'input' by a number of samples given by 'delayInput',
+// which may be fractional and vary over time. The delay is clamped to the
+// range [1, maxDelay]. Each output value is produced before the corresponding
+// input value is received, so it is safe to use inside a feedback loop. The
+// output is 'maxDelay' samples longer than the input, so that the tail is not
+// lost.
+func Fractional(ctx sound.Context, input chan float64, delayInput chan float64, maxDelay int, interpolation Interpolation) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		line := NewLine(maxDelay)
+		tap := Tap{Interpolation: interpolation}
+		tail := maxDelay - 1
+		inputOpen := true
+
+		for d := range delayInput {
+			// The line holds values up to the previous sample, so a delay of d
+			// samples is d-1 pushes ago.
+			d = math.Max(1, math.Min(d, float64(maxDelay)))
+			output <- tap.Read(line, d-1)
+
+			x := 0.0
+			if inputOpen {
+				x, inputOpen = <-input
+			}
+			if !inputOpen {
+				if tail <= 0 {
+					return
+				}
+				tail--
+			}
+
+			line.Push(x)
+		}
+	}()
+
+	return output
+}
+
+// MultiTap mixes copies of 'input' delayed by each of 'delays' (in samples)
+// and scaled by the corresponding entry in 'gains'. A tap with zero delay
+// passes the dry signal. It panics if 'gains' and 'delays' differ in length.
+func MultiTap(ctx sound.Context, input chan float64, delays []float64, gains []float64) (output chan float64) {
+	if len(gains) != len(delays) {
+		panic(fmt.Sprintf("MultiTap: %d gains given for %d delays", len(gains), len(delays)))
+	}
+
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		maxDelay := 0
+		for _, d := range delays {
+			maxDelay = int(math.Max(float64(maxDelay), math.Ceil(d)))
+		}
+
+		line := NewLine(maxDelay)
+		taps := make([]Tap, len(delays))
+
+		for x := range input {
+			line.Push(x)
+
+			y := 0.0
+			for i, d := range delays {
+				y += gains[i] * taps[i].Read(line, d)
+			}
+
+			output <- y
+		}
+	}()
+
+	return output
+}
@@ -0,0 +1,92 @@
+package delay
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"testing"
+	"time"
+)
+
+func TestFixed(t *testing.T) {
+	ctx := sound.DefaultContext
+	input := make(chan float64, 3)
+	input <- 1
+	input <- 2
+	input <- 3
+	close(input)
+
+	var got []float64
+	for x := range Fixed(ctx, input, 2) {
+		got = append(got, x)
+	}
+
+	want := []float64{0, 0, 1, 2, 3}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// A delay longer than the buffering of an unbuffered feedback loop must not
+// deadlock it.
+func TestFixedFeedback(t *testing.T) {
+	ctx := sound.DefaultContext
+	ctx.StreamBufferSize = 0
+	const length = 1000
+
+	impulse := make(chan float64)
+	go func() {
+		impulse <- 1
+		close(impulse)
+	}()
+
+	feedback := make(chan float64)
+	output, copy := ctx.Fork2(ctx.Add(impulse, feedback))
+	go func() {
+		for x := range Fixed(ctx, copy, length) {
+			feedback <- x * 0.5
+		}
+		close(feedback)
+	}()
+
+	done := make(chan []float64)
+	go func() {
+		var got []float64
+		for i := 0; i <= 2*length; i++ {
+			got = append(got, <-output)
+		}
+		done <- got
+	}()
+
+	select {
+	case got := <-done:
+		if got[0] != 1 || got[length] != 0.5 || got[2*length] != 0.25 {
+			t.Errorf("got %v, %v, %v at multiples of the delay", got[0], got[length], got[2*length])
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("feedback loop deadlocked")
+	}
+}
+
+func expectPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	f()
+}
+
+func TestInvalidArguments(t *testing.T) {
+	ctx := sound.DefaultContext
+	input := make(chan float64)
+	gain := make(chan float64)
+
+	expectPanic(t, "MultiTap", func() { MultiTap(ctx, input, []float64{1, 2}, []float64{1}) })
+	expectPanic(t, "FeedforwardComb", func() { FeedforwardComb(ctx, input, 0, gain) })
+	expectPanic(t, "FeedbackComb", func() { FeedbackComb(ctx, input, 0, gain) })
+	expectPanic(t, "AllPass", func() { AllPass(ctx, input, 0, gain) })
+}
@@ -0,0 +1,96 @@
+// Package delay provides delay lines and the filters built from them: comb
+// filters, Schroeder all-pass sections and multi-tap delays.
+package delay
+
+import (
+	"math"
+)
+
+// Interpolation selects how a Tap reads between samples of a Line.
+type Interpolation int
+
+const (
+	LinearInterpolation Interpolation = iota
+	AllPassInterpolation
+	LagrangeInterpolation
+)
+
+// A Line is a circular buffer holding the most recent values pushed to it.
+type Line struct {
+	buffer []float64
+	pos    int // index of the most recently pushed value
+}
+
+// NewLine creates a line able to read up to 'maxDelay' samples into the past.
+func NewLine(maxDelay int) (l *Line) {
+	// Extra room for the interpolators, which look at neighbouring samples.
+	return &Line{
+		buffer: make([]float64, maxDelay+4),
+	}
+}
+
+// MaxDelay returns the longest delay that can be read from the line.
+func (l *Line) MaxDelay() (maxDelay int) {
+	return len(l.buffer) - 4
+}
+
+// Push adds a value to the line, discarding the oldest.
+func (l *Line) Push(x float64) {
+	l.pos = (l.pos + 1) % len(l.buffer)
+	l.buffer[l.pos] = x
+}
+
+// At returns the value pushed 'n' pushes ago, where 0 is the most recent.
+func (l *Line) At(n int) (x float64) {
+	if n < 0 {
+		n = 0
+	} else if n >= len(l.buffer) {
+		n = len(l.buffer) - 1
+	}
+
+	return l.buffer[(l.pos-n+len(l.buffer))%len(l.buffer)]
+}
+
+// A Tap reads fractional delays from a Line. All-pass interpolation is
+// stateful, so every independently modulated read position needs its own Tap.
+type Tap struct {
+	Interpolation Interpolation
+	prevOutput    float64
+}
+
+// Read returns the value 'd' pushes ago (a fractional version of Line.At).
+func (t *Tap) Read(l *Line, d float64) (x float64) {
+	d = math.Max(0, math.Min(d, float64(l.MaxDelay())))
+	k := int(d)
+	f := d - float64(k)
+
+	switch t.Interpolation {
+	case AllPassInterpolation:
+		// Based on https://ccrma.stanford.edu/~jos/pasp/First_Order_Allpass_Interpolation.html
+		eta := (1 - f) / (1 + f)
+		x = l.At(k+1) + eta*(l.At(k)-t.prevOutput)
+		if f == 0 {
+			x = l.At(k)
+		}
+		t.prevOutput = x
+
+	case LagrangeInterpolation:
+		if k < 1 {
+			x = l.At(k)*(1-f) + l.At(k+1)*f
+			break
+		}
+
+		// Third-order interpolation over At(k-1) to At(k+2)
+		dd := 1 + f
+		h0 := -(dd - 1) * (dd - 2) * (dd - 3) / 6
+		h1 := dd * (dd - 2) * (dd - 3) / 2
+		h2 := -dd * (dd - 1) * (dd - 3) / 2
+		h3 := dd * (dd - 1) * (dd - 2) / 6
+		x = h0*l.At(k-1) + h1*l.At(k) + h2*l.At(k+1) + h3*l.At(k+2)
+
+	default:
+		x = l.At(k)*(1-f) + l.At(k+1)*f
+	}
+
+	return x
+}
@@ -0,0 +1,122 @@
+// Package distortion provides waveshaping and lo-fi effects.
+package distortion
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/filter"
+	"math"
+)
+
+// Number of input samples used to compute each oversampled value.
+const oversamplingTaps = 8
+
+// SoftClip is a cubic curve that saturates smoothly at +/-1.
+func SoftClip(x float64) float64 {
+	if x >= 1 {
+		return 1
+	} else if x <= -1 {
+		return -1
+	}
+	return 1.5*x - 0.5*x*x*x
+}
+
+// Tanh is the hyperbolic tangent, which approaches +/-1 asymptotically.
+func Tanh(x float64) float64 {
+	return math.Tanh(x)
+}
+
+// Foldback reflects the signal back into the range [-1, 1] each time it
+// exceeds it.
+func Foldback(x float64) float64 {
+	// Triangle wave with period 4, passing through the origin with slope 1.
+	y := math.Mod(x+1, 4)
+	if y < 0 {
+		y += 4
+	}
+	return 1 - math.Abs(y-2)
+}
+
+// Tube is an asymmetric curve that clips negative half-cycles harder than
+// positive ones, adding even harmonics in the manner of a valve stage.
+func Tube(x float64) float64 {
+	if x >= 0 {
+		return math.Tanh(x)
+	}
+	return math.Tanh(2*x) / 2
+}
+
+// Waveshape passes 'input', multiplied by 'driveInput', through 'curve'. To
+// limit aliasing, the curve is applied at 'oversampling' times the sample
+// rate; this delays the output by a few samples. An oversampling factor of 1
+// disables it.
+func Waveshape(ctx sound.Context, input chan float64, curve sound.MapFunc, driveInput chan float64, oversampling int) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		if oversampling <= 1 {
+			for x := range input {
+				output <- curve(x * (<-driveInput))
+			}
+			return
+		}
+
+		upsampler := filter.NewUpsampler(oversampling, oversamplingTaps)
+		downsampler := filter.NewDownsampler(oversampling, oversamplingTaps)
+		buffer := make([]float64, oversampling)
+
+		for x := range input {
+			drive := <-driveInput
+			upsampler.Process(x, buffer)
+			for i, y := range buffer {
+				buffer[i] = curve(y * drive)
+			}
+			output <- downsampler.Process(buffer)
+		}
+	}()
+
+	return output
+}
+
+// Bitcrush quantises 'input' to the bit depth received from 'bitsInput', which
+// may be fractional for smooth modulation.
+func Bitcrush(ctx sound.Context, input chan float64, bitsInput chan float64) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		for x := range input {
+			levels := math.Pow(2, math.Max(1, <-bitsInput)-1)
+			output <- math.Floor(x*levels+0.5) / levels
+		}
+	}()
+
+	return output
+}
+
+// SampleRateReduce holds each sample of 'input' for the number of samples
+// received from 'factorInput', which may be fractional, imitating a lower
+// sample rate without filtering.
+func SampleRateReduce(ctx sound.Context, input chan float64, factorInput chan float64) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		held := 0.0
+		phase := 1.0
+
+		for x := range input {
+			if phase >= 1 {
+				held = x
+				phase -= math.Floor(phase)
+			}
+			phase += 1 / math.Max(1, <-factorInput)
+			output <- held
+		}
+	}()
+
+	return output
+}
@@ -0,0 +1,410 @@
+// Package dynamics provides feed-forward compressors, limiters, expanders and
+// noise gates.
+package dynamics
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/filter"
+	"math"
+	"time"
+)
+
+// Detection selects how the level of the sidechain signal is measured.
+type Detection int
+
+const (
+	// Absolute value of each sample.
+	Peak Detection = iota
+
+	// Root-mean-square over Params.RMSWindow.
+	RMS
+
+	// Peak of the signal oversampled by four, which catches peaks that fall
+	// between samples and would otherwise clip after conversion.
+	TruePeak
+)
+
+// Params controls a dynamics processor. Levels and gains are in decibels
+// relative to full scale.
+type Params struct {
+	// Level above which (for compressors and limiters) or below which (for
+	// expanders and gates) the gain is changed.
+	Threshold float64
+
+	// Ratio of input level change to output level change beyond the
+	// threshold. Limiters and gates ignore this.
+	Ratio float64
+
+	// Width of the soft knee centred on the threshold. Zero gives a hard knee.
+	Knee float64
+
+	// Time taken for the gain to respond to rising and falling levels.
+	Attack  time.Duration
+	Release time.Duration
+
+	// Gain applied to the output after processing.
+	MakeupGain float64
+
+	Detection Detection
+
+	// Window over which the level is averaged when Detection is RMS.
+	RMSWindow time.Duration
+
+	// Time for which the detected level is held at its peak when Detection is
+	// Peak or TruePeak. Without it the level of a tone drops at every zero
+	// crossing, which makes gates and expanders chatter.
+	Hold time.Duration
+
+	// Delay applied to the audio relative to the sidechain, so that the gain
+	// can start changing before a transient arrives. The output is this much
+	// longer than the input.
+	Lookahead time.Duration
+
+	// Maximum gain reduction of expanders and gates. Zero means unlimited.
+	Range float64
+}
+
+// DefaultCompressor is a Params suitable for Compressor.
+var DefaultCompressor = Params{
+	Threshold: -18.0,
+	Ratio:     4.0,
+	Knee:      6.0,
+	Attack:    time.Millisecond * 10,
+	Release:   time.Millisecond * 100,
+	Detection: RMS,
+	RMSWindow: time.Millisecond * 10,
+}
+
+// DefaultLimiter is a Params suitable for Limiter. It keeps true peaks below
+// -1 dBFS.
+var DefaultLimiter = Params{
+	Threshold: -1.0,
+	Attack:    time.Millisecond * 5,
+	Release:   time.Millisecond * 50,
+	Detection: TruePeak,
+	Lookahead: time.Millisecond * 5,
+}
+
+// DefaultExpander is a Params suitable for Expander.
+var DefaultExpander = Params{
+	Threshold: -40.0,
+	Ratio:     2.0,
+	Knee:      6.0,
+	Attack:    time.Millisecond * 1,
+	Release:   time.Millisecond * 100,
+	Detection: RMS,
+	RMSWindow: time.Millisecond * 10,
+}
+
+// DefaultGate is a Params suitable for Gate.
+var DefaultGate = Params{
+	Threshold: -50.0,
+	Attack:    time.Millisecond * 1,
+	Release:   time.Millisecond * 50,
+	Detection: Peak,
+	Hold:      time.Millisecond * 20,
+	Lookahead: time.Millisecond * 1,
+}
+
+// A gain computer maps a level to a gain, both in decibels.
+type gainComputer func(level float64) (gain float64)
+
+// Compressor reduces the level of 'input' when the level of 'sidechain'
+// exceeds the threshold. If 'sidechain' is nil, the input itself is used.
+func Compressor(ctx sound.Context, input, sidechain chan float64, p Params) (output chan float64) {
+	return process(ctx, input, sidechain, p, compressing, func(level float64) float64 {
+		return compressGain(level, p.Threshold, p.Ratio, p.Knee)
+	})
+}
+
+// Limiter is a compressor with an infinite ratio. In addition to the smoothed
+// gain, every output sample is guaranteed to be reduced at least as much as
+// its own level requires, so the output never exceeds the threshold as
+// measured by the chosen detector. With TruePeak detection the audio is
+// delayed by a further few samples to line it up with the oversampled level.
+func Limiter(ctx sound.Context, input, sidechain chan float64, p Params) (output chan float64) {
+	return process(ctx, input, sidechain, p, limiting, func(level float64) float64 {
+		return compressGain(level, p.Threshold, math.Inf(1), p.Knee)
+	})
+}
+
+// Expander reduces the level of 'input' when the level of 'sidechain' falls
+// below the threshold, widening its dynamic range. If 'sidechain' is nil, the
+// input itself is used.
+func Expander(ctx sound.Context, input, sidechain chan float64, p Params) (output chan float64) {
+	return process(ctx, input, sidechain, p, expanding, func(level float64) float64 {
+		return limitRange(expandGain(level, p.Threshold, p.Ratio, p.Knee), p.Range)
+	})
+}
+
+// Gate silences 'input' (or attenuates it by Range) while the level of
+// 'sidechain' is below the threshold. If 'sidechain' is nil, the input itself
+// is used.
+func Gate(ctx sound.Context, input, sidechain chan float64, p Params) (output chan float64) {
+	return process(ctx, input, sidechain, p, expanding, func(level float64) float64 {
+		if level >= p.Threshold {
+			return 0
+		}
+		return limitRange(math.Inf(-1), p.Range)
+	})
+}
+
+// Kinds of processing, which differ in the direction the gain is smoothed.
+type kind int
+
+const (
+	// Gain reduction follows rising levels at the attack rate.
+	compressing kind = iota
+
+	// As compressing, but with a hard ceiling on the gain.
+	limiting
+
+	// Gain reduction is removed at the attack rate as the level rises.
+	expanding
+)
+
+// Soft-knee compression curve.
+// Based on Giannoulis, Massberg & Reiss, "Digital Dynamic Range Compressor
+// Design - A Tutorial and Analysis", JAES 2012.
+func compressGain(level, threshold, ratio, knee float64) (gain float64) {
+	over := level - threshold
+
+	switch {
+	case 2*over < -knee:
+		return 0
+	case knee > 0 && 2*math.Abs(over) <= knee:
+		x := over + knee/2
+		return (1/ratio - 1) * x * x / (2 * knee)
+	default:
+		return (1/ratio - 1) * over
+	}
+}
+
+// Soft-knee downward expansion curve.
+func expandGain(level, threshold, ratio, knee float64) (gain float64) {
+	over := level - threshold
+
+	switch {
+	case 2*over > knee:
+		return 0
+	case knee > 0 && 2*math.Abs(over) <= knee:
+		x := over - knee/2
+		return -(ratio - 1) * x * x / (2 * knee)
+	default:
+		return (ratio - 1) * over
+	}
+}
+
+func limitRange(gain, rangeDB float64) float64 {
+	if rangeDB > 0 {
+		return math.Max(gain, -rangeDB)
+	}
+	return gain
+}
+
+func process(ctx sound.Context, input, sidechain chan float64, p Params, k kind, computer gainComputer) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	if sidechain == nil {
+		input, sidechain = ctx.Fork2(input)
+	}
+
+	go func() {
+		defer close(output)
+
+		det := newDetector(ctx, p)
+
+		// The detector reports the level of a sample 'latency' samples after
+		// receiving it, so the audio is delayed by that much more than the
+		// lookahead.
+		lookahead := int(ctx.SampleRate * float64(p.Lookahead) / float64(time.Second))
+		delay := lookahead + det.latency
+
+		attack := smoothingCoefficient(ctx, p.Attack)
+		release := smoothingCoefficient(ctx, p.Release)
+		makeup := math.Pow(10, p.MakeupGain/20)
+
+		audio := newRing(delay + 1)
+
+		// Compressors follow the lowest gain within the lookahead, and
+		// expanders the highest, so that both respond before a transient.
+		window := newMinimum(delay + 1)
+		sign := 1.0
+		if k == expanding {
+			sign = -1
+		}
+
+		// The hard ceiling for each sample is the lowest gain over the
+		// samples whose levels it contributes to.
+		ceilings := newRing(lookahead + 1)
+		ceiling := newMinimum(det.latency + 1)
+
+		// Smoothing is done on the linear gain, so that a closed gate opens
+		// at the attack rate rather than climbing from an arbitrary floor.
+		smoothed := 1.0
+		remaining := -1 // samples of tail left after the input closes
+
+		for remaining != 0 {
+			x, ok := <-input
+			s := 0.0
+			if ok {
+				s = <-sidechain
+			} else {
+				if remaining < 0 {
+					remaining = delay
+					if remaining == 0 {
+						break
+					}
+				}
+				remaining--
+				x = 0
+			}
+
+			gain := computer(det.level(s))
+
+			audio.push(x)
+			target := math.Pow(10, sign*window.push(sign*gain)/20)
+
+			// Compressors reduce the gain at the attack rate and recover at
+			// the release rate; expanders open at the attack rate and close
+			// at the release rate.
+			rising := target > smoothed
+			if rising == (k == expanding) {
+				smoothed = attack*smoothed + (1-attack)*target
+			} else {
+				smoothed = release*smoothed + (1-release)*target
+			}
+
+			g := smoothed
+			if k == limiting {
+				ceilings.push(ceiling.push(gain))
+				g = math.Min(g, math.Pow(10, ceilings.oldest()/20))
+			}
+
+			output <- audio.oldest() * g * makeup
+		}
+	}()
+
+	return output
+}
+
+func smoothingCoefficient(ctx sound.Context, t time.Duration) float64 {
+	samples := ctx.SampleRate * float64(t) / float64(time.Second)
+	if samples <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / samples)
+}
+
+// Level detector, returning decibels.
+type detector struct {
+	detection Detection
+	squares   *ring
+	sum       float64
+	hold      *minimum
+	upsampler *filter.Upsampler
+	upsampled []float64
+
+	// Number of samples by which the level lags the input.
+	latency int
+}
+
+// Taps per phase of the TruePeak upsampler.
+const truePeakTaps = 12
+
+func newDetector(ctx sound.Context, p Params) (d *detector) {
+	d = &detector{detection: p.Detection}
+
+	switch p.Detection {
+	case RMS:
+		n := int(ctx.SampleRate * float64(p.RMSWindow) / float64(time.Second))
+		if n < 1 {
+			n = 1
+		}
+		d.squares = newRing(n)
+	case TruePeak:
+		d.upsampler = filter.NewUpsampler(4, truePeakTaps)
+		d.upsampled = make([]float64, 4)
+		d.latency = truePeakTaps/2 + 1
+	}
+
+	if p.Detection != RMS {
+		n := int(ctx.SampleRate*float64(p.Hold)/float64(time.Second)) + 1
+		d.hold = newMinimum(n)
+	}
+
+	return d
+}
+
+func (d *detector) level(x float64) (level float64) {
+	switch d.detection {
+	case RMS:
+		d.sum += x*x - d.squares.oldest()
+		d.squares.push(x * x)
+		level = math.Sqrt(math.Max(d.sum, 0) / float64(len(d.squares.values)))
+
+	case TruePeak:
+		d.upsampler.Process(x, d.upsampled)
+		level = math.Abs(x)
+		for _, y := range d.upsampled {
+			level = math.Max(level, math.Abs(y))
+		}
+
+	default:
+		level = math.Abs(x)
+	}
+
+	if d.hold != nil {
+		level = -d.hold.push(-level)
+	}
+
+	return 20 * math.Log10(math.Max(level, 1e-10))
+}
+
+// Fixed-size history of values.
+type ring struct {
+	values []float64
+	pos    int // index of the oldest value
+}
+
+func newRing(size int) (r *ring) {
+	return &ring{values: make([]float64, size)}
+}
+
+func (r *ring) oldest() float64 {
+	return r.values[r.pos]
+}
+
+func (r *ring) push(x float64) {
+	r.values[r.pos] = x
+	r.pos = (r.pos + 1) % len(r.values)
+}
+
+// Sliding minimum over the last 'size' values, using a monotonic queue.
+type minimum struct {
+	size   int
+	n      int
+	values []float64
+	times  []int
+}
+
+func newMinimum(size int) (m *minimum) {
+	return &minimum{size: size}
+}
+
+func (m *minimum) push(x float64) (min float64) {
+	for len(m.values) > 0 && m.values[len(m.values)-1] >= x {
+		m.values = m.values[:len(m.values)-1]
+		m.times = m.times[:len(m.times)-1]
+	}
+	m.values = append(m.values, x)
+	m.times = append(m.times, m.n)
+
+	if m.times[0] <= m.n-m.size {
+		m.values = m.values[1:]
+		m.times = m.times[1:]
+	}
+
+	m.n++
+	return m.values[0]
+}
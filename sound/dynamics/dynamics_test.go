@@ -0,0 +1,103 @@
+package dynamics
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/filter"
+	"math"
+	"testing"
+	"time"
+)
+
+func samples(ctx sound.Context, t time.Duration) int {
+	return int(ctx.SampleRate * float64(t) / float64(time.Second))
+}
+
+// Run 'buffer' through a processor and collect the output.
+func run(ctx sound.Context, buffer []float64, f func(input chan float64) chan float64) (output []float64) {
+	input := make(chan float64, ctx.StreamBufferSize)
+	go func() {
+		defer close(input)
+		for _, x := range buffer {
+			input <- x
+		}
+	}()
+
+	for y := range f(input) {
+		output = append(output, y)
+	}
+	return output
+}
+
+// Silence followed by a tone, returning the signal and the index of the
+// tone's first sample.
+func toneBurst(ctx sound.Context, freq, amplitude, phase float64) (buffer []float64, onset int) {
+	onset = samples(ctx, time.Millisecond*100)
+	buffer = make([]float64, onset+samples(ctx, time.Millisecond*300))
+	for i := onset; i < len(buffer); i++ {
+		w := 2 * math.Pi * freq * float64(i-onset) / ctx.SampleRate
+		buffer[i] = amplitude * math.Sin(w+phase)
+	}
+	return buffer, onset
+}
+
+func peak(buffer []float64) (level float64) {
+	for _, x := range buffer {
+		level = math.Max(level, math.Abs(x))
+	}
+	return level
+}
+
+func TestGateOpensWithinAttack(t *testing.T) {
+	ctx := sound.DefaultContext
+	p := DefaultGate
+	const freq, amplitude = 440, 0.5
+
+	input, onset := toneBurst(ctx, freq, amplitude, 0)
+	output := run(ctx, input, func(input chan float64) chan float64 {
+		return Gate(ctx, input, nil, p)
+	})
+
+	// The output is delayed by the lookahead.
+	onset += samples(ctx, p.Lookahead)
+	period := int(ctx.SampleRate / freq)
+
+	if level := peak(output[:onset]); level != 0 {
+		t.Errorf("gate open before the tone: peak %v", level)
+	}
+
+	opened := onset + samples(ctx, p.Attack)
+	if level := peak(output[opened : opened+period]); level < amplitude*(1-1/math.E) {
+		t.Errorf("peak %.4f one attack time after onset, want at least %.4f", level, amplitude*(1-1/math.E))
+	}
+
+	// Once open, the gate must stay open through the zero crossings.
+	for i := opened + 4*period; i+period < len(input); i += period {
+		if level := peak(output[i : i+period]); level < amplitude*0.99 {
+			t.Fatalf("gate closed during the tone: peak %.4f at sample %d", level, i)
+		}
+	}
+}
+
+func TestLimiterTruePeak(t *testing.T) {
+	ctx := sound.DefaultContext
+	p := DefaultLimiter
+
+	// A quarter of the sample rate with a phase of 45 degrees: every sample
+	// is at 0.707 of the amplitude, but the peaks fall between samples.
+	input, _ := toneBurst(ctx, ctx.SampleRate/4, 1, math.Pi/4)
+	output := run(ctx, input, func(input chan float64) chan float64 {
+		return Limiter(ctx, input, nil, p)
+	})
+
+	upsampler := filter.NewUpsampler(4, truePeakTaps)
+	upsampled := make([]float64, 4)
+	level := 0.0
+	for _, y := range output {
+		upsampler.Process(y, upsampled)
+		level = math.Max(level, peak(upsampled))
+	}
+
+	if db := 20 * math.Log10(level); db > p.Threshold+0.1 {
+		t.Errorf("true peak %.3f dBFS, want at most %.3f", db, p.Threshold)
+	}
+}
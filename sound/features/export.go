@@ -0,0 +1,91 @@
+package features
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/kierdavis/gosound/music"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes frames to 'w' as CSV with a header row. Each element of the
+// Mel, MFCC and Chroma vectors gets its own column.
+func WriteCSV(w io.Writer, frames chan Frame) (err error) {
+	cw := csv.NewWriter(w)
+	headerWritten := false
+
+	for f := range frames {
+		if !headerWritten {
+			header := []string{"time", "centroid", "bandwidth", "rolloff", "flatness", "flux", "zcr"}
+			for i := range f.Mel {
+				header = append(header, fmt.Sprintf("mel%d", i))
+			}
+			for i := range f.MFCC {
+				header = append(header, fmt.Sprintf("mfcc%d", i))
+			}
+			for i := range f.Chroma {
+				header = append(header, "chroma_"+music.NoteLetter(i).String())
+			}
+
+			err = cw.Write(header)
+			if err != nil {
+				drain(frames)
+				return err
+			}
+			headerWritten = true
+		}
+
+		record := []string{
+			formatFloat(float64(f.Time) / float64(time.Second)),
+			formatFloat(f.Centroid),
+			formatFloat(f.Bandwidth),
+			formatFloat(f.Rolloff),
+			formatFloat(f.Flatness),
+			formatFloat(f.Flux),
+			formatFloat(f.ZeroCrossingRate),
+		}
+		for _, x := range f.Mel {
+			record = append(record, formatFloat(x))
+		}
+		for _, x := range f.MFCC {
+			record = append(record, formatFloat(x))
+		}
+		for _, x := range f.Chroma {
+			record = append(record, formatFloat(x))
+		}
+
+		err = cw.Write(record)
+		if err != nil {
+			drain(frames)
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes frames to 'w' as a JSON array of objects. Times are in
+// nanoseconds.
+func WriteJSON(w io.Writer, frames chan Frame) (err error) {
+	var all []Frame
+	for f := range frames {
+		all = append(all, f)
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(all)
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+func drain(frames chan Frame) {
+	go func() {
+		for _ = range frames {
+		}
+	}()
+}
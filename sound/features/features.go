@@ -0,0 +1,305 @@
+// Package features extracts per-frame spectral features from audio, for
+// classification and analysis.
+package features
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"time"
+)
+
+// A Frame holds the features of one STFT frame. Frequencies are in Hertz.
+type Frame struct {
+	// Time of the start of the frame.
+	Time time.Duration `json:"time"`
+
+	// Magnitude-weighted mean frequency.
+	Centroid float64 `json:"centroid"`
+
+	// Magnitude-weighted standard deviation of frequency about the centroid.
+	Bandwidth float64 `json:"bandwidth"`
+
+	// Frequency below which Params.RolloffFraction of the energy lies.
+	Rolloff float64 `json:"rolloff"`
+
+	// Ratio of the geometric mean to the arithmetic mean of the power
+	// spectrum; near 1 for noise and near 0 for tones.
+	Flatness float64 `json:"flatness"`
+
+	// Euclidean distance between the magnitude spectra of this frame and the
+	// previous one.
+	Flux float64 `json:"flux"`
+
+	// Proportion of consecutive samples that differ in sign.
+	ZeroCrossingRate float64 `json:"zcr"`
+
+	// Log energies of the mel filterbank.
+	Mel []float64 `json:"mel"`
+
+	// Mel-frequency cepstral coefficients.
+	MFCC []float64 `json:"mfcc"`
+
+	// Energy of each pitch class, indexed by music.NoteLetter and normalised
+	// so that the largest is 1.
+	Chroma [12]float64 `json:"chroma"`
+}
+
+// Params controls feature extraction.
+type Params struct {
+	WindowSize int
+	HopSize    int
+
+	RolloffFraction float64
+
+	NumMelBands int
+	NumMFCC     int
+	MinFreq     float64
+	MaxFreq     float64
+
+	// Range of frequencies that contribute to the chroma.
+	MinChromaFreq float64
+	MaxChromaFreq float64
+}
+
+// DefaultParams is a Params with some suitable values filled in.
+var DefaultParams = Params{
+	WindowSize:      2048,
+	HopSize:         512,
+	RolloffFraction: 0.85,
+	NumMelBands:     40,
+	NumMFCC:         13,
+	MinFreq:         0.0,
+	MaxFreq:         8000.0,
+	MinChromaFreq:   55.0,
+	MaxChromaFreq:   5000.0,
+}
+
+// Extract computes the features of each frame of a finite input.
+func Extract(ctx sound.Context, input chan float64, p Params) (frameOutput chan Frame) {
+	frameOutput = make(chan Frame)
+
+	spectrumInput, zcrInput := ctx.Fork2(input)
+	window := fft.HanningWindow(p.WindowSize)
+	spectra := fft.STFT(spectrumInput, window, p.WindowSize-p.HopSize)
+	zcrs := ZeroCrossingRate(zcrInput, p.WindowSize, p.WindowSize-p.HopSize)
+
+	go func() {
+		defer close(frameOutput)
+
+		e := newExtractor(ctx, p)
+		for spectrum := range spectra {
+			f := e.process(spectrum)
+			f.ZeroCrossingRate = <-zcrs
+			frameOutput <- f
+		}
+
+		// Keep the forks moving if the two streams disagree on length.
+		for _ = range zcrs {
+		}
+	}()
+
+	return frameOutput
+}
+
+// FromSpectra computes the spectral features of frames produced by fft.STFT
+// with the window size and hop size given in 'p'. ZeroCrossingRate is not
+// available from a spectrum and is left as zero.
+func FromSpectra(ctx sound.Context, spectra chan []float64, p Params) (frameOutput chan Frame) {
+	frameOutput = make(chan Frame)
+
+	go func() {
+		defer close(frameOutput)
+
+		e := newExtractor(ctx, p)
+		for spectrum := range spectra {
+			frameOutput <- e.process(spectrum)
+		}
+	}()
+
+	return frameOutput
+}
+
+// ZeroCrossingRate computes the zero-crossing rate of each frame of 'input',
+// using the same framing as fft.STFT.
+func ZeroCrossingRate(input chan float64, windowSize int, overlapSize int) (output chan float64) {
+	output = make(chan float64)
+
+	go func() {
+		defer close(output)
+
+		buffer := make([]float64, windowSize)
+		pos := 0
+
+		ok := true
+		for ok {
+			for pos < windowSize {
+				buffer[pos], ok = <-input
+				pos++
+			}
+
+			crossings := 0
+			for i := 1; i < windowSize; i++ {
+				if (buffer[i-1] < 0) != (buffer[i] < 0) {
+					crossings++
+				}
+			}
+			output <- float64(crossings) / float64(windowSize-1)
+
+			copy(buffer, buffer[windowSize-overlapSize:])
+			pos = overlapSize
+		}
+	}()
+
+	return output
+}
+
+type extractor struct {
+	ctx        sound.Context
+	p          Params
+	fftSize    int
+	melBank    [][]float64
+	chromaBins []int // pitch class of each bin, or -1
+	prevMags   []float64
+	index      int
+}
+
+func newExtractor(ctx sound.Context, p Params) (e *extractor) {
+	// The STFT pads each frame to a power of two, so the bins are spaced
+	// according to the padded size rather than the window size.
+	fftSize := fft.PaddedSize(p.WindowSize)
+
+	e = &extractor{
+		ctx:     ctx,
+		p:       p,
+		fftSize: fftSize,
+		melBank: fft.MelFilterbank(ctx.SampleRate, fftSize, p.NumMelBands, p.MinFreq, p.MaxFreq),
+	}
+
+	e.chromaBins = make([]int, fftSize/2+1)
+	for k := range e.chromaBins {
+		freq := e.binFreq(k)
+		if freq >= p.MinChromaFreq && freq <= p.MaxChromaFreq {
+			e.chromaBins[k] = int(music.FromFrequency(freq).Letter())
+		} else {
+			e.chromaBins[k] = -1
+		}
+	}
+
+	return e
+}
+
+func (e *extractor) binFreq(k int) float64 {
+	return float64(k) * e.ctx.SampleRate / float64(e.fftSize)
+}
+
+func (e *extractor) process(spectrum []float64) (f Frame) {
+	// Only the first half of the spectrum of a real signal is unique.
+	power := spectrum[:len(spectrum)/2+1]
+	mags := make([]float64, len(power))
+	for k, x := range power {
+		mags[k] = math.Sqrt(x)
+	}
+
+	f.Time = time.Duration(float64(e.index*e.p.HopSize) / e.ctx.SampleRate * float64(time.Second))
+	e.index++
+
+	// Centroid and bandwidth
+	sumMags, sumWeighted := 0.0, 0.0
+	for k, m := range mags {
+		sumMags += m
+		sumWeighted += m * e.binFreq(k)
+	}
+	if sumMags > 0 {
+		f.Centroid = sumWeighted / sumMags
+
+		spread := 0.0
+		for k, m := range mags {
+			d := e.binFreq(k) - f.Centroid
+			spread += m * d * d
+		}
+		f.Bandwidth = math.Sqrt(spread / sumMags)
+	}
+
+	// Rolloff
+	total := 0.0
+	for _, x := range power {
+		total += x
+	}
+	cumulative := 0.0
+	for k, x := range power {
+		cumulative += x
+		if cumulative >= e.p.RolloffFraction*total {
+			f.Rolloff = e.binFreq(k)
+			break
+		}
+	}
+
+	// Flatness, computed in the log domain to avoid underflow
+	const epsilon = 1e-20
+	logSum := 0.0
+	for _, x := range power {
+		logSum += math.Log(x + epsilon)
+	}
+	if total > 0 {
+		f.Flatness = math.Exp(logSum/float64(len(power))) / (total / float64(len(power)))
+	}
+
+	// Flux
+	if e.prevMags != nil {
+		sum := 0.0
+		for k, m := range mags {
+			d := m - e.prevMags[k]
+			sum += d * d
+		}
+		f.Flux = math.Sqrt(sum)
+	}
+	e.prevMags = mags
+
+	// Mel energies and MFCCs
+	f.Mel = fft.ApplyFilterbank(e.melBank, power)
+	for b, x := range f.Mel {
+		f.Mel[b] = math.Log(x + epsilon)
+	}
+	f.MFCC = dct(f.Mel, e.p.NumMFCC)
+
+	// Chroma
+	for k, x := range power {
+		if c := e.chromaBins[k]; c >= 0 {
+			f.Chroma[c] += x
+		}
+	}
+	max := 0.0
+	for _, x := range f.Chroma {
+		max = math.Max(max, x)
+	}
+	if max > 0 {
+		for c := range f.Chroma {
+			f.Chroma[c] /= max
+		}
+	}
+
+	return f
+}
+
+// First 'n' coefficients of the orthonormal DCT-II of 'x'.
+func dct(x []float64, n int) (coeffs []float64) {
+	coeffs = make([]float64, n)
+	size := float64(len(x))
+
+	for k := range coeffs {
+		sum := 0.0
+		for i, v := range x {
+			sum += v * math.Cos(math.Pi*float64(k)*(float64(i)+0.5)/size)
+		}
+
+		if k == 0 {
+			coeffs[k] = sum * math.Sqrt(1/size)
+		} else {
+			coeffs[k] = sum * math.Sqrt(2/size)
+		}
+	}
+
+	return coeffs
+}
@@ -0,0 +1,39 @@
+package features
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"math"
+	"testing"
+)
+
+// A window size that is not a power of two is padded by the STFT, and the
+// bin frequencies must account for it.
+func TestExtractUnpaddedWindow(t *testing.T) {
+	ctx := sound.DefaultContext
+	p := DefaultParams
+	p.WindowSize = 1000
+	p.HopSize = 250
+
+	const freq = 1000.0
+	buffer := make([]float64, 8000)
+	for i := range buffer {
+		buffer[i] = math.Sin(2 * math.Pi * freq * float64(i) / ctx.SampleRate)
+	}
+
+	n := 0
+	for f := range Extract(ctx, ctx.FromBuffer(buffer), p) {
+		// Skip frames that run past the end of the tone.
+		start := int(f.Time.Seconds()*ctx.SampleRate + 0.5)
+		if start+p.WindowSize > len(buffer) {
+			continue
+		}
+
+		n++
+		if math.Abs(f.Centroid-freq) > 50 {
+			t.Errorf("frame at %v: centroid %.1f Hz, want about %.0f Hz", f.Time, f.Centroid, freq)
+		}
+	}
+	if n == 0 {
+		t.Fatalf("no frames")
+	}
+}
@@ -0,0 +1,108 @@
+package fft
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"math"
+	"math/cmplx"
+)
+
+// Spectral kernel values smaller than this are discarded.
+const cqtSparsity = 0.0054
+
+// ConstantQFrequencies returns the centre frequency of each bin of a
+// constant-Q transform starting at 'minNote'. When 'binsPerOctave' is a
+// multiple of 12, every (binsPerOctave/12)th bin lies on a note.
+func ConstantQFrequencies(minNote music.Note, numBins int, binsPerOctave int) (freqs []float64) {
+	freqs = make([]float64, numBins)
+	f0 := minNote.Frequency()
+	for k := range freqs {
+		freqs[k] = f0 * math.Pow(2, float64(k)/float64(binsPerOctave))
+	}
+	return freqs
+}
+
+type cqtEntry struct {
+	bin   int
+	value complex128
+}
+
+// Rolling constant-Q transform over an input channel, producing the squared
+// magnitude of each bin every 'hopSize' samples. Unlike STFT, the bins are
+// spaced geometrically (see ConstantQFrequencies) and each bin's resolution
+// is proportional to its frequency.
+// Based on Brown & Puckette, "An efficient algorithm for the calculation of a
+// constant Q transform", JASA 1992.
+func ConstantQ(input chan float64, sampleRate float64, minNote music.Note, numBins int, binsPerOctave int, hopSize int) (output chan []float64) {
+	output = make(chan []float64)
+
+	freqs := ConstantQFrequencies(minNote, numBins, binsPerOctave)
+	q := 1 / (math.Pow(2, 1/float64(binsPerOctave)) - 1)
+
+	// The lowest bin has the longest kernel.
+	fftSize := int(clp2(uint64(math.Ceil(q * sampleRate / freqs[0]))))
+
+	// Compute the spectral kernel of each bin, keeping only its significant
+	// values.
+	kernels := make([][]cqtEntry, numBins)
+	temporal := make([]complex128, fftSize)
+	spectral := make([]complex128, fftSize)
+	for k, freq := range freqs {
+		length := int(math.Ceil(q * sampleRate / freq))
+		start := (fftSize - length) / 2
+
+		for i := range temporal {
+			temporal[i] = 0
+		}
+		for n := 0; n < length; n++ {
+			w := 0.54 - 0.46*math.Cos(2*math.Pi*float64(n)/float64(length-1))
+			phase := 2 * math.Pi * q * float64(n) / float64(length)
+			temporal[start+n] = cmplx.Rect(w/float64(length), phase)
+		}
+
+		fftComplex(spectral, temporal, 1)
+		for j, c := range spectral {
+			if cmplx.Abs(c) >= cqtSparsity {
+				kernels[k] = append(kernels[k], cqtEntry{j, cmplx.Conj(c) / complex(float64(fftSize), 0)})
+			}
+		}
+	}
+
+	frames := ComplexSTFT(input, RectangularWindow(fftSize), fftSize-hopSize)
+
+	go func() {
+		defer close(output)
+
+		for frame := range frames {
+			result := make([]float64, numBins)
+			for k, kernel := range kernels {
+				var sum complex128
+				for _, e := range kernel {
+					sum += frame[e.bin] * e.value
+				}
+				x, y := real(sum), imag(sum)
+				result[k] = x*x + y*y
+			}
+
+			output <- result
+		}
+	}()
+
+	return output
+}
+
+// Rolling mel-scale spectrogram over an input channel, producing the energy in
+// each band of a MelFilterbank for every STFT frame.
+func MelSpectrogram(input chan float64, sampleRate float64, window []float64, overlapSize int, numBands int, minFreq, maxFreq float64) (output chan []float64) {
+	output = make(chan []float64)
+	bank := MelFilterbank(sampleRate, PaddedSize(len(window)), numBands, minFreq, maxFreq)
+
+	go func() {
+		defer close(output)
+
+		for spectrum := range STFT(input, window, overlapSize) {
+			output <- ApplyFilterbank(bank, spectrum)
+		}
+	}()
+
+	return output
+}
@@ -18,6 +18,12 @@ func clp2(x uint64) uint64 {
 	return x + 1
 }
 
+// PaddedSize returns the length of the transform of 'n' samples: FFT and the
+// STFTs pad their input with zeros up to the next power of two.
+func PaddedSize(n int) int {
+	return int(clp2(uint64(n)))
+}
+
 // Fast Fourier transform using Cooley-Tukey algorithm.
 func FFT(input []float64) (output []complex128) {
 	output = make([]complex128, clp2(uint64(len(input))))
@@ -53,3 +59,52 @@ func fft(output []complex128, input []float64, stride int) {
 		output[k+p] = t - e
 	}
 }
+
+// Inverse fast Fourier transform. The input is zero-padded to a power of two.
+func IFFT(input []complex128) (output []complex128) {
+	n := int(clp2(uint64(len(input))))
+
+	// Conjugating before and after a forward transform gives the inverse.
+	conj := make([]complex128, n)
+	for i, c := range input {
+		conj[i] = cmplx.Conj(c)
+	}
+
+	output = make([]complex128, n)
+	fftComplex(output, conj, 1)
+
+	for i, c := range output {
+		output[i] = cmplx.Conj(c) / complex(float64(n), 0)
+	}
+
+	return output
+}
+
+func fftComplex(output []complex128, input []complex128, stride int) {
+	n := len(output)
+	p := n / 2
+
+	if n == 1 {
+		if len(input) > 0 {
+			output[0] = input[0]
+		} else {
+			output[0] = complex(0, 0)
+		}
+		return
+	}
+
+	var oddInput []complex128
+	if len(input) > stride {
+		oddInput = input[stride:]
+	}
+
+	fftComplex(output[:p], input, stride*2)
+	fftComplex(output[p:], oddInput, stride*2)
+
+	for k, t := range output[:p] {
+		a := complex(0, -2*math.Pi*float64(k)/float64(n))
+		e := cmplx.Exp(a) * output[k+p]
+		output[k] = t + e
+		output[k+p] = t - e
+	}
+}
@@ -0,0 +1,64 @@
+package fft
+
+import (
+	"math"
+)
+
+// HzToMel converts a frequency in Hertz to the mel scale (HTK formula).
+func HzToMel(freq float64) (mel float64) {
+	return 2595 * math.Log10(1+freq/700)
+}
+
+// MelToHz converts a mel scale value to a frequency in Hertz.
+func MelToHz(mel float64) (freq float64) {
+	return 700 * (math.Pow(10, mel/2595) - 1)
+}
+
+// MelFilterbank creates 'numBands' triangular filters spaced evenly on the mel
+// scale between 'minFreq' and 'maxFreq', for spectra produced by an FFT of size
+// 'fftSize'. bank[band][bin] is the weight of each bin up to the Nyquist
+// frequency in each band.
+func MelFilterbank(sampleRate float64, fftSize int, numBands int, minFreq, maxFreq float64) (bank [][]float64) {
+	numBins := fftSize/2 + 1
+	minMel := HzToMel(minFreq)
+	maxMel := HzToMel(maxFreq)
+
+	// Band edges, including the lower edge of the first band and the upper
+	// edge of the last.
+	edges := make([]float64, numBands+2)
+	for i := range edges {
+		edges[i] = MelToHz(minMel + (maxMel-minMel)*float64(i)/float64(numBands+1))
+	}
+
+	bank = make([][]float64, numBands)
+	for b := range bank {
+		bank[b] = make([]float64, numBins)
+		lower, centre, upper := edges[b], edges[b+1], edges[b+2]
+
+		for k := range bank[b] {
+			freq := float64(k) * sampleRate / float64(fftSize)
+			switch {
+			case freq > lower && freq <= centre:
+				bank[b][k] = (freq - lower) / (centre - lower)
+			case freq > centre && freq < upper:
+				bank[b][k] = (upper - freq) / (upper - centre)
+			}
+		}
+	}
+
+	return bank
+}
+
+// ApplyFilterbank returns the energy of 'spectrum' (squared magnitudes, as
+// produced by STFT) in each band of 'bank'.
+func ApplyFilterbank(bank [][]float64, spectrum []float64) (energies []float64) {
+	energies = make([]float64, len(bank))
+	for b, weights := range bank {
+		for k, w := range weights {
+			if w != 0 && k < len(spectrum) {
+				energies[b] += w * spectrum[k]
+			}
+		}
+	}
+	return energies
+}
@@ -0,0 +1,44 @@
+package fft
+
+import (
+	"math"
+	"testing"
+)
+
+// With a window that is not a power of two, the frames are padded and the
+// filterbank must be built for the padded size.
+func TestMelSpectrogramUnpaddedWindow(t *testing.T) {
+	const sampleRate, freq = 44100.0, 4000.0
+	const numBands, maxFreq = 128, 8000.0
+
+	input := make(chan float64)
+	go func() {
+		defer close(input)
+		for i := 0; i < 4000; i++ {
+			input <- math.Sin(2 * math.Pi * freq * float64(i) / sampleRate)
+		}
+	}()
+
+	// Centre of each band, as spaced by MelFilterbank.
+	maxMel := HzToMel(maxFreq)
+	centre := func(b int) float64 {
+		return MelToHz(maxMel * float64(b+1) / float64(numBands+1))
+	}
+
+	frames := 0
+	for energies := range MelSpectrogram(input, sampleRate, HanningWindow(1000), 500, numBands, 0, maxFreq) {
+		frames++
+		loudest := 0
+		for b, x := range energies {
+			if x > energies[loudest] {
+				loudest = b
+			}
+		}
+		if math.Abs(centre(loudest)-freq) > (centre(loudest+1)-centre(loudest))/2 {
+			t.Errorf("loudest band centred on %.0f Hz, want %.0f Hz", centre(loudest), freq)
+		}
+	}
+	if frames == 0 {
+		t.Fatalf("no frames")
+	}
+}
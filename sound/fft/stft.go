@@ -4,10 +4,35 @@ import (
 	"math"
 )
 
-// Rolling short-time Fourier transform over an input channel.
+// Rolling short-time Fourier transform over an input channel, producing the
+// squared magnitude of each bin.
 func STFT(input chan float64, window []float64, overlapSize int) (output chan []float64) {
 	output = make(chan []float64)
 
+	go func() {
+		defer close(output)
+
+		for cresult := range ComplexSTFT(input, window, overlapSize) {
+			// Find the squared magnitudes and put them into a result array.
+			result := make([]float64, len(cresult))
+			for i, c := range cresult {
+				x, y := real(c), imag(c)
+				result[i] = x*x + y*y
+			}
+
+			// Send the result
+			output <- result
+		}
+	}()
+
+	return output
+}
+
+// Rolling short-time Fourier transform over an input channel, producing the
+// complex value of each bin.
+func ComplexSTFT(input chan float64, window []float64, overlapSize int) (output chan []complex128) {
+	output = make(chan []complex128)
+
 	go func() {
 		defer close(output)
 
@@ -36,18 +61,8 @@ func STFT(input chan float64, window []float64, overlapSize int) (output chan []
 				buffer[i] *= window[i]
 			}
 
-			// FFT it
-			cresult := FFT(buffer)
-
-			// Find the squared magnitudes and put them into a result array.
-			result := make([]float64, len(cresult))
-			for i, c := range cresult {
-				x, y := real(c), imag(c)
-				result[i] = x*x + y*y
-			}
-
-			// Send the result
-			output <- result
+			// FFT it and send the result
+			output <- FFT(buffer)
 
 			// Copy the overlap to the start of the buffer.
 			copy(buffer, overlap)
@@ -0,0 +1,129 @@
+package filter
+
+// Coefficients receives a single set of coefficients from the channels
+// returned by a coefficient generator such as ChebyshevCoefficients. Nil
+// channels (such as 'bs[0]') produce a zero coefficient.
+func Coefficients(as, bs []chan float64) (asValues, bsValues []float64) {
+	asValues = make([]float64, len(as))
+	bsValues = make([]float64, len(bs))
+
+	for i, a := range as {
+		if a != nil {
+			asValues[i] = <-a
+		}
+	}
+
+	for i, b := range bs {
+		if b != nil {
+			bsValues[i] = <-b
+		}
+	}
+
+	return asValues, bsValues
+}
+
+// FiltFilt applies a recursive filter with fixed coefficients (in the form
+// taken by Recursive) to 'buffer' once forwards and once backwards, resulting
+// in a zero-phase output with the squared magnitude response of the filter.
+// FIR kernels can be applied by passing them as 'as' with nil 'bs'.
+//
+// The ends of the buffer are padded with an odd reflection of the signal and
+// the filter state is initialised to its steady-state response to the first
+// sample, to reduce transients at the edges.
+func FiltFilt(buffer []float64, as, bs []float64) (output []float64) {
+	if len(buffer) == 0 {
+		return nil
+	}
+
+	n := len(as)
+	if len(bs) > n {
+		n = len(bs)
+	}
+
+	padSize := 3 * n
+	if padSize > len(buffer)-1 {
+		padSize = len(buffer) - 1
+	}
+
+	// Odd reflection about the first and last samples
+	padded := make([]float64, len(buffer)+2*padSize)
+	first, last := buffer[0], buffer[len(buffer)-1]
+	for i := 0; i < padSize; i++ {
+		padded[i] = 2*first - buffer[padSize-i]
+		padded[len(padded)-1-i] = 2*last - buffer[len(buffer)-1-padSize+i]
+	}
+	copy(padded[padSize:], buffer)
+
+	filterBuffer(padded, as, bs)
+	reverse(padded)
+	filterBuffer(padded, as, bs)
+	reverse(padded)
+
+	output = make([]float64, len(buffer))
+	copy(output, padded[padSize:])
+	return output
+}
+
+// FIRFiltFilt applies an FIR kernel with FiltFilt.
+func FIRFiltFilt(buffer []float64, kernel []float64) (output []float64) {
+	return FiltFilt(buffer, kernel, nil)
+}
+
+// Filter 'buffer' in place using the same difference equation as Recursive,
+// with the state primed as though the first sample had been held forever.
+func filterBuffer(buffer []float64, as, bs []float64) {
+	if len(buffer) == 0 {
+		return
+	}
+
+	// DC gain of the filter
+	sa := 0.0
+	for _, a := range as {
+		sa += a
+	}
+	sb := 0.0
+	for i := 1; i < len(bs); i++ {
+		sb += bs[i]
+	}
+	gain := sa / (1 - sb)
+
+	x0 := buffer[0]
+	prevInputs := make([]float64, len(as)+1)
+	prevOutputs := make([]float64, len(bs)+1)
+	for i := range prevInputs {
+		prevInputs[i] = x0
+	}
+	for i := range prevOutputs {
+		prevOutputs[i] = x0 * gain
+	}
+	prevInputPtr := 0
+	prevOutputPtr := 0
+
+	for n, x := range buffer {
+		y := 0.0
+		if len(as) > 0 {
+			y = as[0] * x
+		}
+
+		for i := 1; i < len(as); i++ {
+			y += as[i] * prevInputs[(prevInputPtr-i+1+len(prevInputs))%len(prevInputs)]
+		}
+
+		for i := 1; i < len(bs); i++ {
+			y += bs[i] * prevOutputs[(prevOutputPtr-i+1+len(prevOutputs))%len(prevOutputs)]
+		}
+
+		buffer[n] = y
+
+		prevInputPtr = (prevInputPtr + 1) % len(prevInputs)
+		prevInputs[prevInputPtr] = x
+		prevOutputPtr = (prevOutputPtr + 1) % len(prevOutputs)
+		prevOutputs[prevOutputPtr] = y
+	}
+}
+
+func reverse(buffer []float64) {
+	for i, j := 0, len(buffer)-1; i < j; i, j = i+1, j-1 {
+		buffer[i], buffer[j] = buffer[j], buffer[i]
+	}
+}
@@ -0,0 +1,98 @@
+package filter
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// Measure the amplitude and phase (relative to a sine) of the component of
+// 'buffer' at 'freq', over whole periods in the middle half of the buffer.
+func measureTone(buffer []float64, freq, sampleRate float64) (amplitude, phase float64) {
+	period := sampleRate / freq
+	start := len(buffer) / 4
+	n := int(math.Floor(float64(len(buffer)/2)/period) * period)
+
+	var s, c float64
+	for i := start; i < start+n; i++ {
+		w := 2 * math.Pi * freq * float64(i) / sampleRate
+		s += buffer[i] * math.Sin(w)
+		c += buffer[i] * math.Cos(w)
+	}
+	s *= 2 / float64(n)
+	c *= 2 / float64(n)
+
+	return math.Hypot(s, c), math.Atan2(c, s)
+}
+
+// Magnitude of the frequency response of a filter in the form taken by
+// Recursive.
+func response(as, bs []float64, freq, sampleRate float64) float64 {
+	w := 2 * math.Pi * freq / sampleRate
+	var num, den complex128 = 0, 1
+	for i, a := range as {
+		num += complex(a, 0) * cmplx.Exp(complex(0, -w*float64(i)))
+	}
+	for i := 1; i < len(bs); i++ {
+		den -= complex(bs[i], 0) * cmplx.Exp(complex(0, -w*float64(i)))
+	}
+	return cmplx.Abs(num / den)
+}
+
+func sine(freq, sampleRate float64, n int) (buffer []float64) {
+	buffer = make([]float64, n)
+	for i := range buffer {
+		buffer[i] = math.Sin(2 * math.Pi * freq * float64(i) / sampleRate)
+	}
+	return buffer
+}
+
+func checkZeroPhase(t *testing.T, name string, output []float64, freq, sampleRate, wantAmplitude float64) {
+	amplitude, phase := measureTone(output, freq, sampleRate)
+	if math.Abs(phase) > 1e-3 {
+		t.Errorf("%s at %.0f Hz: phase shift %.5f rad, want 0", name, freq, phase)
+	}
+	if math.Abs(amplitude-wantAmplitude) > 1e-3 {
+		t.Errorf("%s at %.0f Hz: amplitude %.5f, want %.5f", name, freq, amplitude, wantAmplitude)
+	}
+}
+
+func TestFiltFiltRecursive(t *testing.T) {
+	ctx := sound.DefaultContext
+
+	cutoff := make(chan float64, 1)
+	cutoff <- 1000
+	close(cutoff)
+	asChans, bsChans := RCCoefficients(ctx, LowPass, cutoff)
+	as, bs := Coefficients(asChans, bsChans)
+
+	for _, freq := range []float64{100, 1000, 5000} {
+		output := FiltFilt(sine(freq, ctx.SampleRate, 44100), as, bs)
+		gain := response(as, bs, freq, ctx.SampleRate)
+		checkZeroPhase(t, "RC", output, freq, ctx.SampleRate, gain*gain)
+	}
+}
+
+func TestFiltFiltFIR(t *testing.T) {
+	ctx := sound.DefaultContext
+	kernel := WindowedSinc(ctx, LowPass, 2000, 101)
+
+	for _, freq := range []float64{200, 2000, 8000} {
+		output := FIRFiltFilt(sine(freq, ctx.SampleRate, 44100), kernel)
+		gain := response(kernel, nil, freq, ctx.SampleRate)
+		checkZeroPhase(t, "FIR", output, freq, ctx.SampleRate, gain*gain)
+	}
+}
+
+func TestRCCoefficientsClose(t *testing.T) {
+	cutoff := make(chan float64)
+	close(cutoff)
+	as, bs := RCCoefficients(sound.DefaultContext, HighPass, cutoff)
+
+	for _, c := range []chan float64{as[0], as[1], bs[1]} {
+		if _, ok := <-c; ok {
+			t.Errorf("coefficient channel not closed")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"math"
+)
+
+// Run a finite impulse response filter by convolving the input with 'kernel'.
+func FIR(ctx sound.Context, input chan float64, kernel []float64) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		prevInputs := make([]float64, len(kernel))
+		ptr := 0 // points to most recently added prevInput
+
+		for x := range input {
+			ptr = (ptr + 1) % len(prevInputs)
+			prevInputs[ptr] = x
+
+			y := 0.0
+			for i, k := range kernel {
+				y += k * prevInputs[(ptr-i+len(prevInputs))%len(prevInputs)]
+			}
+
+			output <- y
+		}
+	}()
+
+	return output
+}
+
+// Design a windowed-sinc FIR kernel of the given length (which should be odd)
+// with a Blackman window.
+// Based on http://www.dspguide.com/ch16/2.htm
+func WindowedSinc(ctx sound.Context, filterType FilterType, cutoffFreq float64, length int) (kernel []float64) {
+	kernel = sincKernel(cutoffFreq/ctx.SampleRate, length)
+
+	// Spectral inversion turns the low-pass kernel into a high-pass one
+	if filterType == HighPass {
+		for i := range kernel {
+			kernel[i] = -kernel[i]
+		}
+		kernel[length/2] += 1.0
+	}
+
+	return kernel
+}
+
+// Low-pass windowed-sinc kernel with cutoff 'fc' (as a fraction of the sample
+// rate), normalised for unity gain at DC.
+func sincKernel(fc float64, length int) (kernel []float64) {
+	kernel = make([]float64, length)
+	m := float64(length - 1)
+
+	sum := 0.0
+	for i := range kernel {
+		x := float64(i) - m/2
+		if x == 0 {
+			kernel[i] = 2 * math.Pi * fc
+		} else {
+			kernel[i] = math.Sin(2*math.Pi*fc*x) / x
+		}
+
+		kernel[i] *= 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/m) + 0.08*math.Cos(4*math.Pi*float64(i)/m)
+		sum += kernel[i]
+	}
+
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
@@ -0,0 +1,84 @@
+package filter
+
+// An Upsampler increases the sample rate of a signal by an integer factor,
+// using a polyphase windowed-sinc interpolation filter.
+type Upsampler struct {
+	factor       int
+	phases       [][]float64
+	prevInputs   []float64
+	prevInputPtr int
+}
+
+// NewUpsampler creates an Upsampler that multiplies the sample rate by
+// 'factor', using 'tapsPerPhase' input samples to compute each output. The
+// output is delayed by roughly tapsPerPhase/2 input samples.
+func NewUpsampler(factor int, tapsPerPhase int) (u *Upsampler) {
+	kernel := sincKernel(0.5/float64(factor), factor*tapsPerPhase)
+
+	phases := make([][]float64, factor)
+	for p := range phases {
+		phases[p] = make([]float64, tapsPerPhase)
+		for k := range phases[p] {
+			// The zero-stuffed signal has 1/factor of the energy, so make up
+			// the gain here.
+			phases[p][k] = kernel[p+k*factor] * float64(factor)
+		}
+	}
+
+	return &Upsampler{
+		factor:     factor,
+		phases:     phases,
+		prevInputs: make([]float64, tapsPerPhase),
+	}
+}
+
+// Process consumes one input sample and writes 'factor' output samples into
+// 'output'.
+func (u *Upsampler) Process(x float64, output []float64) {
+	n := len(u.prevInputs)
+	u.prevInputPtr = (u.prevInputPtr + 1) % n
+	u.prevInputs[u.prevInputPtr] = x
+
+	for p, phase := range u.phases {
+		y := 0.0
+		for k, h := range phase {
+			y += h * u.prevInputs[(u.prevInputPtr-k+n)%n]
+		}
+		output[p] = y
+	}
+}
+
+// A Downsampler decreases the sample rate of a signal by an integer factor,
+// low-pass filtering it first to prevent aliasing.
+type Downsampler struct {
+	kernel       []float64
+	prevInputs   []float64
+	prevInputPtr int
+}
+
+// NewDownsampler creates a Downsampler that divides the sample rate by
+// 'factor', using a filter 'factor*tapsPerPhase' samples long.
+func NewDownsampler(factor int, tapsPerPhase int) (d *Downsampler) {
+	kernel := sincKernel(0.5/float64(factor), factor*tapsPerPhase)
+
+	return &Downsampler{
+		kernel:     kernel,
+		prevInputs: make([]float64, len(kernel)),
+	}
+}
+
+// Process consumes 'factor' input samples and returns one output sample.
+func (d *Downsampler) Process(input []float64) (y float64) {
+	n := len(d.prevInputs)
+
+	for _, x := range input {
+		d.prevInputPtr = (d.prevInputPtr + 1) % n
+		d.prevInputs[d.prevInputPtr] = x
+	}
+
+	for k, h := range d.kernel {
+		y += h * d.prevInputs[(d.prevInputPtr-k+n)%n]
+	}
+
+	return y
+}
@@ -54,3 +54,41 @@ func RC(ctx sound.Context, input chan float64, filterType FilterType, cutoffFreq
 
 	return output
 }
+
+// RCCoefficients produces coefficients for Recursive that are equivalent to
+// the RC filter.
+func RCCoefficients(ctx sound.Context, filterType FilterType, cutoffFreqInput chan float64) (asOutput, bsOutput []chan float64) {
+	asOutput = make([]chan float64, 2)
+	bsOutput = make([]chan float64, 2)
+	asOutput[0] = make(chan float64, ctx.StreamBufferSize)
+	asOutput[1] = make(chan float64, ctx.StreamBufferSize)
+	bsOutput[1] = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(asOutput[0])
+		defer close(asOutput[1])
+		defer close(bsOutput[1])
+
+		dt := 1.0 / ctx.SampleRate
+
+		for cutoffFreq := range cutoffFreqInput {
+			rc := 1.0 / (2.0 * math.Pi * cutoffFreq)
+
+			switch filterType {
+			case LowPass:
+				alpha := dt / (rc + dt)
+				asOutput[0] <- alpha
+				asOutput[1] <- 0.0
+				bsOutput[1] <- 1.0 - alpha
+
+			case HighPass:
+				alpha := rc / (rc + dt)
+				asOutput[0] <- alpha
+				asOutput[1] <- -alpha
+				bsOutput[1] <- alpha
+			}
+		}
+	}()
+
+	return asOutput, bsOutput
+}
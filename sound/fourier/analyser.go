@@ -0,0 +1,117 @@
+package fourier
+
+import (
+	"github.com/kierdavis/gosound/music"
+)
+
+// NoteFrequencies returns the frequency in Hertz of every note from 'lowest'
+// to 'highest' inclusive, for use with Analyse.
+func NoteFrequencies(lowest, highest music.Note) (freqs []float64) {
+	for note := lowest; note <= highest; note = note.Add(1) {
+		freqs = append(freqs, note.Frequency())
+	}
+	return freqs
+}
+
+// Analyse runs 'f' over successive windows of 'input', advancing by 'hopSize'
+// samples each time, and sends the magnitude at each of 'freqs' (in Hertz).
+// 'f' must already be initialised with an input size equal to len(window) and
+// len(freqs) frequencies; it is not released when the input ends. The last
+// window is padded with zeroes. 'hopSize' must be at least 1, and the window
+// must not sum to zero.
+//
+// Magnitudes are normalised by the gain of the window, so a sinusoid with
+// amplitude A at one of the frequencies produces a value of A regardless of
+// window shape and size.
+func Analyse(f Fourier, input chan float64, sampleRate float64, freqs []float64, window []float64, hopSize int) (output chan []float64, errChan chan error) {
+	output = make(chan []float64)
+	errChan = make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(output)
+
+		// Drain the input if we give up early, so upstream goroutines finish.
+		// This happens in the background so that the output is closed
+		// straight away, even if the input never ends.
+		defer func() {
+			go func() {
+				for _ = range input {
+				}
+			}()
+		}()
+
+		if hopSize < 1 {
+			errChan <- InvalidHopSizeError{hopSize}
+			return
+		}
+
+		// The kernels divide by the block size; multiply that back out and
+		// divide by the window's gain instead. A sinusoid's energy is split
+		// between positive and negative frequencies, hence the factor of 2.
+		windowGain := 0.0
+		for _, w := range window {
+			windowGain += w
+		}
+		if windowGain == 0 {
+			errChan <- ZeroWindowGainError{}
+			return
+		}
+		scale := 2 * float64(len(window)) / windowGain
+
+		normFreqs := make([]float32, len(freqs))
+		for i, freq := range freqs {
+			normFreqs[i] = float32(freq / sampleRate)
+		}
+
+		err := f.WriteFreqs(normFreqs)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		windowSize := len(window)
+		buffer := make([]float64, windowSize)
+		block := make([]float32, windowSize)
+		pos := 0
+
+		ok := true
+		for ok {
+			// Top up the buffer, padding with zeroes once the input closes.
+			for pos < windowSize {
+				buffer[pos], ok = <-input
+				pos++
+			}
+
+			for i, x := range buffer {
+				block[i] = float32(x * window[i])
+			}
+
+			magnitudes, err := f.Transform(block)
+			if err != nil {
+				errChan <- err
+				return
+			}
+
+			result := make([]float64, len(magnitudes))
+			for i, m := range magnitudes {
+				result[i] = float64(m) * scale
+			}
+			output <- result
+
+			// Keep the overlap for the next window.
+			if hopSize < windowSize {
+				copy(buffer, buffer[hopSize:])
+				pos = windowSize - hopSize
+			} else {
+				// Skip the samples between windows.
+				for i := windowSize; i < hopSize && ok; i++ {
+					_, ok = <-input
+				}
+				pos = 0
+			}
+		}
+	}()
+
+	return output, errChan
+}
@@ -0,0 +1,117 @@
+package fourier
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAnalyse(t *testing.T) {
+	ctx := sound.DefaultContext
+	const amplitude = 0.5
+	freqs := []float64{220, 440, 880}
+	window := fft.HanningWindow(1 << logInputSize)
+
+	buffer := make([]float64, 8192)
+	for i := range buffer {
+		buffer[i] = amplitude * math.Sin(2*math.Pi*440*float64(i)/ctx.SampleRate)
+	}
+
+	f := NewCPU(logInputSize, len(freqs))
+	f.Init()
+	defer f.Release()
+
+	output, errChan := Analyse(f, ctx.FromBuffer(buffer), ctx.SampleRate, freqs, window, 512)
+
+	// Skip the windows that overlap the end of the input.
+	full := (len(buffer)-len(window))/512 + 1
+	n := 0
+	for mags := range output {
+		if n < full {
+			if math.Abs(mags[1]-amplitude) > 0.01 {
+				t.Errorf("window %d: magnitude at 440 Hz %.4f, want %.4f", n, mags[1], amplitude)
+			}
+			if mags[0] > 0.01 || mags[2] > 0.01 {
+				t.Errorf("window %d: magnitudes away from the tone %v", n, mags)
+			}
+		}
+		n++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	if n < full {
+		t.Errorf("got %d windows, want at least %d", n, full)
+	}
+}
+
+// An error must close the output even if the input never ends.
+func TestAnalyseErrorUnboundedInput(t *testing.T) {
+	ctx := sound.DefaultContext
+	f := NewCPU(logInputSize, 1) // not initialised
+
+	output, errChan := Analyse(f, ctx.Const(1), ctx.SampleRate, []float64{440}, fft.HanningWindow(1<<logInputSize), 512)
+
+	done := make(chan bool)
+	go func() {
+		for _ = range output {
+		}
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatal("output not closed after an error")
+	}
+
+	if err := <-errChan; err != (NotInitialisedError{}) {
+		t.Errorf("got error %v, want NotInitialisedError", err)
+	}
+}
+
+func TestAnalyseInvalidArguments(t *testing.T) {
+	ctx := sound.DefaultContext
+	f := NewCPU(logInputSize, 1)
+	f.Init()
+	defer f.Release()
+
+	window := fft.HanningWindow(1 << logInputSize)
+	zeroWindow := make([]float64, len(window))
+
+	for _, test := range []struct {
+		window  []float64
+		hopSize int
+		want    error
+	}{
+		{window, 0, InvalidHopSizeError{0}},
+		{window, -1, InvalidHopSizeError{-1}},
+		{zeroWindow, 512, ZeroWindowGainError{}},
+	} {
+		output, errChan := Analyse(f, ctx.Const(1), ctx.SampleRate, []float64{440}, test.window, test.hopSize)
+
+		done := make(chan int)
+		go func() {
+			n := 0
+			for _ = range output {
+				n++
+			}
+			done <- n
+		}()
+
+		select {
+		case n := <-done:
+			if n != 0 {
+				t.Errorf("hop size %d: got %d windows, want none", test.hopSize, n)
+			}
+		case <-time.After(time.Second * 10):
+			t.Fatalf("hop size %d: output not closed", test.hopSize)
+		}
+
+		if err := <-errChan; err != test.want {
+			t.Errorf("hop size %d: got error %v, want %v", test.hopSize, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package fourier
+
+import (
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"math/cmplx"
+	"runtime"
+	"sync"
+)
+
+// CPUFourier is a pure Go implementation of the same computation as
+// clfourier.CLFourier, for machines without an OpenCL device. Frequencies are
+// evaluated with the Goertzel algorithm, split between several goroutines. If
+// every frequency falls exactly on a bin of a 2^logInputSize point FFT and
+// there are enough of them, a single FFT is used instead.
+type CPUFourier struct {
+	// Number of goroutines to use; if zero, runtime.NumCPU() is used.
+	NumWorkers int
+
+	logInputSize uint
+	numFreqs     int
+
+	initialised bool
+	input       []float64
+	freqs       []float64
+	output      []float32
+}
+
+func NewCPU(logInputSize uint, numFreqs int) (c *CPUFourier) {
+	return &CPUFourier{
+		logInputSize: logInputSize,
+		numFreqs:     numFreqs,
+	}
+}
+
+func (c *CPUFourier) Init() (err error) {
+	if c.initialised {
+		return AlreadyInitialisedError{}
+	}
+
+	c.input = make([]float64, 1<<c.logInputSize)
+	c.freqs = make([]float64, c.numFreqs)
+	c.output = make([]float32, c.numFreqs)
+	c.initialised = true
+	return nil
+}
+
+func (c *CPUFourier) Release() {
+	c.input = nil
+	c.freqs = nil
+	c.output = nil
+	c.initialised = false
+}
+
+func (c *CPUFourier) WriteInput(input []float32) (err error) {
+	if !c.initialised {
+		return NotInitialisedError{}
+	}
+
+	if len(input) != len(c.input) {
+		return InvalidInputSizeError{len(c.input), len(input)}
+	}
+
+	for i, x := range input {
+		c.input[i] = float64(x)
+	}
+	return nil
+}
+
+func (c *CPUFourier) WriteFreqs(freqs []float32) (err error) {
+	if !c.initialised {
+		return NotInitialisedError{}
+	}
+
+	if len(freqs) != c.numFreqs {
+		return InvalidInputSizeError{c.numFreqs, len(freqs)}
+	}
+
+	for i, f := range freqs {
+		c.freqs[i] = float64(f)
+	}
+	return nil
+}
+
+func (c *CPUFourier) ReadOutput() (output []float32, err error) {
+	if !c.initialised {
+		return nil, NotInitialisedError{}
+	}
+
+	output = make([]float32, c.numFreqs)
+	copy(output, c.output)
+	return output, nil
+}
+
+func (c *CPUFourier) Transform(input []float32) (output []float32, err error) {
+	err = c.WriteInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ReadOutput()
+}
+
+func (c *CPUFourier) Run() (err error) {
+	if !c.initialised {
+		return NotInitialisedError{}
+	}
+
+	if bins, ok := c.fftBins(); ok {
+		c.runFFT(bins)
+	} else {
+		c.runGoertzel()
+	}
+
+	return nil
+}
+
+// If every frequency lies on an FFT bin and the FFT would be cheaper than
+// running Goertzel for each, return the bins.
+func (c *CPUFourier) fftBins() (bins []int, ok bool) {
+	n := len(c.input)
+	if uint(c.numFreqs) <= c.logInputSize {
+		return nil, false
+	}
+
+	bins = make([]int, c.numFreqs)
+	for i, f := range c.freqs {
+		bin := f * float64(n)
+		if bin != math.Floor(bin) {
+			return nil, false
+		}
+		bins[i] = ((int(bin) % n) + n) % n
+	}
+
+	return bins, true
+}
+
+func (c *CPUFourier) runFFT(bins []int) {
+	n := float64(len(c.input))
+	spectrum := fft.FFT(c.input)
+	for i, bin := range bins {
+		c.output[i] = float32(cmplx.Abs(spectrum[bin]) / n)
+	}
+}
+
+func (c *CPUFourier) runGoertzel() {
+	numWorkers := c.NumWorkers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	if numWorkers > c.numFreqs {
+		numWorkers = c.numFreqs
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			// Workers take every numWorkers'th frequency.
+			for i := w; i < c.numFreqs; i += numWorkers {
+				c.output[i] = float32(goertzel(c.input, c.freqs[i]) / float64(len(c.input)))
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
+
+// Magnitude of the discrete-time Fourier transform of 'input' at 'freq'
+// cycles per sample.
+func goertzel(input []float64, freq float64) float64 {
+	w := 2 * math.Pi * freq
+	cos, sin := math.Cos(w), math.Sin(w)
+	coeff := 2 * cos
+
+	s1, s2 := 0.0, 0.0
+	for _, x := range input {
+		s1, s2 = x+coeff*s1-s2, s1
+	}
+
+	re := s1 - s2*cos
+	im := s2 * sin
+	return math.Sqrt(re*re + im*im)
+}
@@ -0,0 +1,118 @@
+package fourier
+
+import (
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+const logInputSize = 10
+
+func randomBlock() (block []float32) {
+	r := rand.New(rand.NewSource(1))
+	block = make([]float32, 1<<logInputSize)
+	for i := range block {
+		block[i] = float32(r.Float64()*2 - 1)
+	}
+	return block
+}
+
+// Magnitudes of the FFT of 'block' at 'bins', divided by the block size.
+func fftMagnitudes(block []float32, bins []int) (mags []float64) {
+	input := make([]float64, len(block))
+	for i, x := range block {
+		input[i] = float64(x)
+	}
+	spectrum := fft.FFT(input)
+
+	mags = make([]float64, len(bins))
+	for i, bin := range bins {
+		mags[i] = cmplx.Abs(spectrum[bin]) / float64(len(block))
+	}
+	return mags
+}
+
+func transform(t *testing.T, block []float32, freqs []float32) (output []float32) {
+	c := NewCPU(logInputSize, len(freqs))
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Release()
+
+	if err := c.WriteFreqs(freqs); err != nil {
+		t.Fatal(err)
+	}
+	output, err := c.Transform(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func checkAgainstFFT(t *testing.T, bins []int) {
+	block := randomBlock()
+	freqs := make([]float32, len(bins))
+	for i, bin := range bins {
+		freqs[i] = float32(bin) / float32(len(block))
+	}
+
+	got := transform(t, block, freqs)
+	want := fftMagnitudes(block, bins)
+	for i := range bins {
+		if math.Abs(float64(got[i])-want[i]) > 1e-5 {
+			t.Errorf("bin %d: got %.6f, want %.6f", bins[i], got[i], want[i])
+		}
+	}
+}
+
+// Few frequencies are evaluated with Goertzel.
+func TestCPUGoertzel(t *testing.T) {
+	checkAgainstFFT(t, []int{0, 3, 100, 511})
+}
+
+// Many frequencies on bins are evaluated with a single FFT.
+func TestCPUFFT(t *testing.T) {
+	bins := make([]int, 64)
+	for i := range bins {
+		bins[i] = i * 8
+	}
+	checkAgainstFFT(t, bins)
+}
+
+// Frequencies between bins match the DTFT computed directly.
+func TestCPUBetweenBins(t *testing.T) {
+	block := randomBlock()
+	freqs := []float32{0.01234, 0.25 + 0.3/1024, 0.4321}
+
+	got := transform(t, block, freqs)
+	for i, f := range freqs {
+		var sum complex128
+		for n, x := range block {
+			sum += complex(float64(x), 0) * cmplx.Exp(complex(0, -2*math.Pi*float64(f)*float64(n)))
+		}
+		want := cmplx.Abs(sum) / float64(len(block))
+		if math.Abs(float64(got[i])-want) > 1e-5 {
+			t.Errorf("frequency %v: got %.6f, want %.6f", f, got[i], want)
+		}
+	}
+}
+
+func TestCPUErrors(t *testing.T) {
+	c := NewCPU(logInputSize, 2)
+	if _, err := c.Transform(randomBlock()); err != (NotInitialisedError{}) {
+		t.Errorf("Transform before Init: got %v", err)
+	}
+
+	c.Init()
+	if err := c.Init(); err != (AlreadyInitialisedError{}) {
+		t.Errorf("second Init: got %v", err)
+	}
+	if err := c.WriteFreqs([]float32{0.1}); err == nil {
+		t.Errorf("WriteFreqs with the wrong number of frequencies succeeded")
+	}
+	if _, err := c.Transform(make([]float32, 10)); err == nil {
+		t.Errorf("Transform with the wrong block size succeeded")
+	}
+}
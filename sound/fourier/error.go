@@ -0,0 +1,40 @@
+package fourier
+
+import (
+	"fmt"
+)
+
+type NotInitialisedError struct{}
+
+func (_ NotInitialisedError) Error() string {
+	return "CPUFourier is not initialised"
+}
+
+type AlreadyInitialisedError struct{}
+
+func (_ AlreadyInitialisedError) Error() string {
+	return "CPUFourier is already initialised"
+}
+
+type InvalidInputSizeError struct {
+	Expected int
+	Got      int
+}
+
+func (err InvalidInputSizeError) Error() string {
+	return fmt.Sprintf("Expected input (sample data / frequencies) of size %d, but got %d", err.Expected, err.Got)
+}
+
+type InvalidHopSizeError struct {
+	HopSize int
+}
+
+func (err InvalidHopSizeError) Error() string {
+	return fmt.Sprintf("Hop size must be at least 1, but got %d", err.HopSize)
+}
+
+type ZeroWindowGainError struct{}
+
+func (_ ZeroWindowGainError) Error() string {
+	return "Window has zero gain"
+}
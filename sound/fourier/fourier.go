@@ -0,0 +1,18 @@
+// Package fourier evaluates the discrete-time Fourier transform of blocks of
+// samples at arbitrary frequencies. It is written in pure Go; the clfourier
+// package provides an OpenCL implementation of the same interface.
+package fourier
+
+// Fourier is implemented by CPUFourier and clfourier.CLFourier. Each computes,
+// for a block of 2^logInputSize samples, the magnitude of its discrete-time
+// Fourier transform at each of a set of frequencies (in cycles per sample),
+// divided by the block size.
+type Fourier interface {
+	Init() error
+	Release()
+	WriteInput(input []float32) error
+	WriteFreqs(freqs []float32) error
+	Run() error
+	ReadOutput() ([]float32, error)
+	Transform(input []float32) ([]float32, error)
+}
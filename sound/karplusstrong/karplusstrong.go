@@ -2,6 +2,7 @@ package karplusstrong
 
 import (
     "github.com/kierdavis/gosound/sound"
+    "github.com/kierdavis/gosound/sound/delay"
     "github.com/kierdavis/gosound/sound/filter"
 )
 
@@ -13,24 +14,6 @@ func pipe(input, output chan float64) {
     close(output)
 }
 
-// Delay line suitable for use in feedback systems without causing deadlock.
-func delay(ctx sound.Context, input chan float64, length uint) (output chan float64) {
-    output = make(chan float64, ctx.StreamBufferSize)
-    
-    go func() {
-        buffer := make([]float64, length)
-        pos := uint(0)
-        
-        for {
-            output <- buffer[pos]
-            buffer[pos] = <-input
-            pos = (pos + 1) % length
-        }
-    }()
-    
-    return output
-}
-
 // input should be finite and preferably short (e.g. one cycle of a triangle wave)
 func KarplusStrong(ctx sound.Context, input chan float64, delaySamples uint, cutoff float64, decay float64) (output chan float64) {
     feedback := make(chan float64, ctx.StreamBufferSize)
@@ -42,7 +25,7 @@ func KarplusStrong(ctx sound.Context, input chan float64, delaySamples uint, cut
     output, outputCopy := ctx.Fork2(output)
     
     // The copy is first passed through a delay line...
-    outputCopy = delay(ctx, outputCopy, delaySamples)
+    outputCopy = delay.Fixed(ctx, outputCopy, delaySamples)
     
     // ...then filtered...
     //outputCopy = filter.Chebyshev(ctx, outputCopy, filter.LowPass, cutoff, 0.5, 2)
@@ -0,0 +1,359 @@
+package meter
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"math"
+	"sort"
+)
+
+// LoudnessReport summarises the loudness of a programme according to ITU-R
+// BS.1770-4 and EBU R128. Loudness values are in LUFS, the range in LU and
+// peaks in dBFS. Values are -Inf for silent programmes.
+type LoudnessReport struct {
+	Integrated   float64
+	Range        float64
+	MaxMomentary float64
+	MaxShortTerm float64
+	SamplePeak   float64
+	TruePeak     float64
+}
+
+const (
+	absoluteGate      = -70.0
+	relativeGate      = -10.0 // for integrated loudness
+	rangeRelativeGate = -20.0 // for loudness range
+
+	// Measurements are made over sub-blocks of 100ms; momentary loudness
+	// covers 4 sub-blocks and short-term loudness 30.
+	subBlocksPerSecond = 10
+	momentarySubBlocks = 4
+	shortTermSubBlocks = 30
+)
+
+// Loudness passes 'inputs' through to 'outputs' and reports their combined
+// loudness. 'weights' gives the weighting of each channel; if it is nil, every
+// channel has a weight of 1. BS.1770 specifies 1.41 for surround channels and
+// 0 for LFE. The outputs end when every input is closed.
+func Loudness(ctx sound.Context, inputs []chan float64, weights []float64) (outputs []chan float64, report chan LoudnessReport) {
+	report = make(chan LoudnessReport, 1)
+
+	m := newLoudnessMeter(ctx, len(inputs), weights)
+	outputs = passFrames(ctx, inputs, m.process, func() {
+		report <- m.report()
+		close(report)
+	})
+
+	return outputs, report
+}
+
+// MomentaryLoudness passes 'inputs' through to 'outputs' and sends the
+// momentary loudness (over the last 400ms, updated every 100ms) for every
+// sample to 'loudnessOutput'. See Loudness for the meaning of 'weights'.
+func MomentaryLoudness(ctx sound.Context, inputs []chan float64, weights []float64) (outputs []chan float64, loudnessOutput chan float64) {
+	return loudnessStream(ctx, inputs, weights, momentarySubBlocks)
+}
+
+// ShortTermLoudness is like MomentaryLoudness but measures over the last 3
+// seconds.
+func ShortTermLoudness(ctx sound.Context, inputs []chan float64, weights []float64) (outputs []chan float64, loudnessOutput chan float64) {
+	return loudnessStream(ctx, inputs, weights, shortTermSubBlocks)
+}
+
+func loudnessStream(ctx sound.Context, inputs []chan float64, weights []float64, numSubBlocks int) (outputs []chan float64, loudnessOutput chan float64) {
+	loudnessOutput = make(chan float64, ctx.StreamBufferSize)
+
+	m := newLoudnessMeter(ctx, len(inputs), weights)
+	outputs = passFrames(ctx, inputs, func(frame []float64) {
+		m.process(frame)
+		loudnessOutput <- m.windowLoudness(numSubBlocks)
+	}, func() {
+		close(loudnessOutput)
+	})
+
+	return outputs, loudnessOutput
+}
+
+// Copy frames from 'inputs' to the returned outputs, calling 'process' on each
+// frame and 'done' once every input is closed. Closed inputs read as zero
+// until the others close.
+func passFrames(ctx sound.Context, inputs []chan float64, process func([]float64), done func()) (outputs []chan float64) {
+	inputs2 := make([]chan float64, len(inputs))
+	copy(inputs2, inputs)
+	inputs = inputs2
+
+	outputs = make([]chan float64, len(inputs))
+	outputsCopy := make([]chan float64, len(inputs))
+	for i := range outputs {
+		ch := make(chan float64, ctx.StreamBufferSize)
+		outputs[i] = ch
+		outputsCopy[i] = ch
+	}
+
+	go func() {
+		frame := make([]float64, len(inputs))
+
+		for {
+			open := 0
+			for i, input := range inputs {
+				frame[i] = 0
+				if input == nil {
+					continue
+				}
+
+				x, ok := <-input
+				if !ok {
+					inputs[i] = nil
+					close(outputsCopy[i])
+					continue
+				}
+
+				frame[i] = x
+				open++
+			}
+
+			if open == 0 {
+				break
+			}
+
+			process(frame)
+
+			for i, x := range frame {
+				if inputs[i] != nil {
+					outputsCopy[i] <- x
+				}
+			}
+		}
+
+		done()
+	}()
+
+	return outputs
+}
+
+// Biquad filter in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) (y float64) {
+	y = f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// K-weighting filter: a high shelf modelling the acoustic effect of the head
+// followed by a high-pass (the "RLB" curve). The coefficients are computed for
+// the context's sample rate rather than the 48 kHz values given in BS.1770.
+// Based on libebur128.
+func newKWeighting(sampleRate float64) (shelf, highPass biquad) {
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+
+	k := math.Tan(math.Pi * f0 / sampleRate)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+
+	shelf = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / sampleRate)
+	a0 = 1 + k/q + k*k
+
+	highPass = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return shelf, highPass
+}
+
+type loudnessMeter struct {
+	weights    []float64
+	shelves    []biquad
+	highPasses []biquad
+	truePeaks  []*truePeakDetector
+
+	subBlockSize int
+	subBlockPos  int
+	sums         []float64 // per-channel sums of squares in the current sub-block
+
+	// Weighted mean squares of every completed sub-block.
+	subBlocks []float64
+
+	momentary  []float64
+	shortTerm  []float64
+	samplePeak float64
+}
+
+func newLoudnessMeter(ctx sound.Context, numChannels int, weights []float64) (m *loudnessMeter) {
+	if weights == nil {
+		weights = make([]float64, numChannels)
+		for i := range weights {
+			weights[i] = 1.0
+		}
+	}
+
+	m = &loudnessMeter{
+		weights:      weights,
+		shelves:      make([]biquad, numChannels),
+		highPasses:   make([]biquad, numChannels),
+		truePeaks:    make([]*truePeakDetector, numChannels),
+		subBlockSize: int(ctx.SampleRate / subBlocksPerSecond),
+		sums:         make([]float64, numChannels),
+	}
+
+	for i := 0; i < numChannels; i++ {
+		m.shelves[i], m.highPasses[i] = newKWeighting(ctx.SampleRate)
+		m.truePeaks[i] = newTruePeakDetector()
+	}
+
+	return m
+}
+
+func (m *loudnessMeter) process(frame []float64) {
+	for i, x := range frame {
+		m.samplePeak = math.Max(m.samplePeak, math.Abs(x))
+		m.truePeaks[i].process(x)
+
+		y := m.highPasses[i].process(m.shelves[i].process(x))
+		m.sums[i] += y * y
+	}
+
+	m.subBlockPos++
+	if m.subBlockPos < m.subBlockSize {
+		return
+	}
+
+	z := 0.0
+	for i, sum := range m.sums {
+		z += m.weights[i] * sum / float64(m.subBlockSize)
+		m.sums[i] = 0
+	}
+	m.subBlockPos = 0
+	m.subBlocks = append(m.subBlocks, z)
+
+	if len(m.subBlocks) >= momentarySubBlocks {
+		m.momentary = append(m.momentary, m.windowLoudness(momentarySubBlocks))
+	}
+	if len(m.subBlocks) >= shortTermSubBlocks {
+		m.shortTerm = append(m.shortTerm, m.windowLoudness(shortTermSubBlocks))
+	}
+}
+
+// Loudness of the most recent 'n' completed sub-blocks.
+func (m *loudnessMeter) windowLoudness(n int) (loudness float64) {
+	if n > len(m.subBlocks) {
+		n = len(m.subBlocks)
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	sum := 0.0
+	for _, z := range m.subBlocks[len(m.subBlocks)-n:] {
+		sum += z
+	}
+
+	return energyToLoudness(sum / float64(n))
+}
+
+func energyToLoudness(z float64) float64 {
+	return -0.691 + 10*math.Log10(z)
+}
+
+func loudnessToEnergy(l float64) float64 {
+	return math.Pow(10, (l+0.691)/10)
+}
+
+func (m *loudnessMeter) report() (r LoudnessReport) {
+	r.Integrated = integratedLoudness(m.momentary)
+	r.Range = loudnessRange(m.shortTerm)
+	r.MaxMomentary = maxOf(m.momentary)
+	r.MaxShortTerm = maxOf(m.shortTerm)
+	r.SamplePeak = ToDecibels(m.samplePeak)
+
+	truePeak := 0.0
+	for _, tp := range m.truePeaks {
+		truePeak = math.Max(truePeak, tp.peak)
+	}
+	r.TruePeak = ToDecibels(truePeak)
+
+	return r
+}
+
+// Mean energy of the blocks louder than 'gate'.
+func gatedMean(blocks []float64, gate float64) (mean float64, n int) {
+	for _, l := range blocks {
+		if l > gate {
+			mean += loudnessToEnergy(l)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0, 0
+	}
+	return mean / float64(n), n
+}
+
+// Integrated loudness of a series of 400ms block loudnesses, per BS.1770-4.
+func integratedLoudness(blocks []float64) float64 {
+	mean, n := gatedMean(blocks, absoluteGate)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	gate := energyToLoudness(mean) + relativeGate
+	mean, n = gatedMean(blocks, gate)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	return energyToLoudness(mean)
+}
+
+// Loudness range of a series of 3s block loudnesses, per EBU Tech 3342.
+func loudnessRange(blocks []float64) float64 {
+	mean, n := gatedMean(blocks, absoluteGate)
+	if n == 0 {
+		return 0
+	}
+
+	gate := energyToLoudness(mean) + rangeRelativeGate
+	var gated []float64
+	for _, l := range blocks {
+		if l > absoluteGate && l > gate {
+			gated = append(gated, l)
+		}
+	}
+	if len(gated) == 0 {
+		return 0
+	}
+
+	sort.Float64s(gated)
+	low := gated[int(math.Floor(0.10*float64(len(gated)-1)+0.5))]
+	high := gated[int(math.Floor(0.95*float64(len(gated)-1)+0.5))]
+	return high - low
+}
+
+func maxOf(values []float64) float64 {
+	max := math.Inf(-1)
+	for _, x := range values {
+		max = math.Max(max, x)
+	}
+	return max
+}
@@ -0,0 +1,126 @@
+// Package meter provides operators that pass audio through unchanged while
+// measuring its level.
+//
+// Functions that produce a final report send a single value on the returned
+// report channel once the input is closed; the audio outputs must still be
+// read (or drained) for the measurement to complete.
+package meter
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/filter"
+	"math"
+	"time"
+)
+
+// RMS passes 'input' through to 'output' and sends the root-mean-square level
+// of the most recent 'window' of it, for every sample, to 'rmsOutput'. Note
+// that both output channels must be read from; sound.Context.Drain can be used
+// on one that is not used.
+func RMS(ctx sound.Context, input chan float64, window time.Duration) (output, rmsOutput chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+	rmsOutput = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+		defer close(rmsOutput)
+
+		n := int(ctx.SampleRate * float64(window) / float64(time.Second))
+		if n < 1 {
+			n = 1
+		}
+
+		squares := make([]float64, n)
+		pos := 0
+		sum := 0.0
+
+		for x := range input {
+			sum += x*x - squares[pos]
+			squares[pos] = x * x
+			pos = (pos + 1) % n
+
+			output <- x
+			rmsOutput <- math.Sqrt(math.Max(sum, 0) / float64(n))
+		}
+	}()
+
+	return output, rmsOutput
+}
+
+// Peak passes 'input' through to 'output' and reports the largest absolute
+// sample value.
+func Peak(ctx sound.Context, input chan float64) (output chan float64, report chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+	report = make(chan float64, 1)
+
+	go func() {
+		defer close(output)
+		defer close(report)
+
+		peak := 0.0
+		for x := range input {
+			peak = math.Max(peak, math.Abs(x))
+			output <- x
+		}
+
+		report <- peak
+	}()
+
+	return output, report
+}
+
+// TruePeak passes 'input' through to 'output' and reports the largest absolute
+// value of the signal oversampled by four, per ITU-R BS.1770. This estimates
+// the peaks of the reconstructed analogue signal, which may lie between
+// samples.
+func TruePeak(ctx sound.Context, input chan float64) (output chan float64, report chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+	report = make(chan float64, 1)
+
+	go func() {
+		defer close(output)
+		defer close(report)
+
+		tp := newTruePeakDetector()
+		for x := range input {
+			tp.process(x)
+			output <- x
+		}
+
+		report <- tp.peak
+	}()
+
+	return output, report
+}
+
+type truePeakDetector struct {
+	upsampler *filter.Upsampler
+	buffer    []float64
+	peak      float64
+}
+
+func newTruePeakDetector() (tp *truePeakDetector) {
+	return &truePeakDetector{
+		upsampler: filter.NewUpsampler(4, 12),
+		buffer:    make([]float64, 4),
+	}
+}
+
+func (tp *truePeakDetector) process(x float64) {
+	tp.peak = math.Max(tp.peak, math.Abs(x))
+	tp.upsampler.Process(x, tp.buffer)
+	for _, y := range tp.buffer {
+		tp.peak = math.Max(tp.peak, math.Abs(y))
+	}
+}
+
+// ToDecibels converts a linear level to decibels relative to full scale.
+func ToDecibels(level float64) (db float64) {
+	return 20 * math.Log10(level)
+}
+
+// FromDecibels converts a level in decibels relative to full scale to a
+// linear level.
+func FromDecibels(db float64) (level float64) {
+	return math.Pow(10, db/20)
+}
@@ -0,0 +1,90 @@
+package modulation
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/delay"
+	"math"
+	"time"
+)
+
+// DelayParams controls Chorus, Flanger and ModulatedDelay.
+type DelayParams struct {
+	// Centre delay time.
+	Delay time.Duration
+
+	// Maximum deviation from the centre delay. This should not exceed Delay.
+	Depth time.Duration
+
+	// Proportion of the delayed signal fed back into the delay line, from -1
+	// to 1.
+	Feedback float64
+
+	// Proportion of the delayed signal in the output, from 0 (dry) to 1.
+	Mix float64
+}
+
+// DefaultChorus is a DelayParams suitable for Chorus.
+var DefaultChorus = DelayParams{
+	Delay:    time.Millisecond * 20,
+	Depth:    time.Millisecond * 5,
+	Feedback: 0.0,
+	Mix:      0.5,
+}
+
+// DefaultFlanger is a DelayParams suitable for Flanger.
+var DefaultFlanger = DelayParams{
+	Delay:    time.Millisecond * 3,
+	Depth:    time.Millisecond * 2,
+	Feedback: 0.7,
+	Mix:      0.5,
+}
+
+// Chorus thickens 'input' by mixing it with a copy whose delay is slowly
+// modulated by 'lfoInput'. Start from DefaultChorus.
+func Chorus(ctx sound.Context, input, lfoInput chan float64, p DelayParams) (output chan float64) {
+	return ModulatedDelay(ctx, input, lfoInput, p)
+}
+
+// Flanger is like Chorus but with a much shorter delay and usually some
+// feedback, producing a sweeping comb filter. Start from DefaultFlanger.
+func Flanger(ctx sound.Context, input, lfoInput chan float64, p DelayParams) (output chan float64) {
+	return ModulatedDelay(ctx, input, lfoInput, p)
+}
+
+// Vibrato modulates the pitch of 'input' by varying its delay by up to
+// 'depth' either side of its centre.
+func Vibrato(ctx sound.Context, input, lfoInput chan float64, depth time.Duration) (output chan float64) {
+	return ModulatedDelay(ctx, input, lfoInput, DelayParams{
+		Delay: depth,
+		Depth: depth,
+		Mix:   1.0,
+	})
+}
+
+// ModulatedDelay mixes 'input' with a copy whose delay is varied by
+// 'lfoInput' around p.Delay. Use DefaultChorus or DefaultFlanger as a starting
+// point for 'p'.
+func ModulatedDelay(ctx sound.Context, input, lfoInput chan float64, p DelayParams) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		centre := ctx.SampleRate * float64(p.Delay) / float64(time.Second)
+		depth := ctx.SampleRate * float64(p.Depth) / float64(time.Second)
+
+		line := delay.NewLine(int(math.Ceil(centre+depth)) + 1)
+		tap := delay.Tap{Interpolation: delay.LagrangeInterpolation}
+
+		for x := range input {
+			// The line holds values up to the previous sample.
+			d := math.Max(1, centre+depth*(<-lfoInput))
+			wet := tap.Read(line, d-1)
+			line.Push(x + p.Feedback*wet)
+
+			output <- x*(1-p.Mix) + wet*p.Mix
+		}
+	}()
+
+	return output
+}
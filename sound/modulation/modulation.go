@@ -0,0 +1,61 @@
+// Package modulation provides time-modulated effects such as chorus, flanger
+// and phaser. Each effect is driven by an LFO stream in the range [-1, 1],
+// such as one produced by sound.Context.Sine or sound.Context.Triangle.
+package modulation
+
+import (
+	"github.com/kierdavis/gosound/sound"
+)
+
+// An Effect processes 'input' under the control of 'lfoInput'. Wrap the
+// functions in this package in a closure to use them with Spread.
+type Effect func(input, lfoInput chan float64) (output chan float64)
+
+// Shape selects the waveform of an LFO.
+type Shape int
+
+const (
+	Sine Shape = iota
+	Triangle
+)
+
+// LFO produces an infinite low-frequency oscillator of the given shape and
+// rate (in Hertz). 'phase' lies in the interval [0,1].
+func LFO(ctx sound.Context, shape Shape, rate float64, phase float64) (output chan float64) {
+	switch shape {
+	case Triangle:
+		return ctx.TriangleWithPhase(ctx.Const(rate), phase)
+	default:
+		return ctx.SineWithPhase(ctx.Const(rate), phase)
+	}
+}
+
+// StereoLFO produces a pair of LFOs whose phases differ by 'spread' (from 0
+// to 1, where 0.25 is a quarter cycle), suitable for use with Spread.
+func StereoLFO(ctx sound.Context, shape Shape, rate float64, spread float64) (left, right chan float64) {
+	return LFO(ctx, shape, rate, 0), LFO(ctx, shape, rate, spread)
+}
+
+// Spread applies 'effect' to two copies of 'input', each driven by its own
+// LFO, producing a stereo result.
+func Spread(ctx sound.Context, input chan float64, lfoLeft, lfoRight chan float64, effect Effect) (left, right chan float64) {
+	leftInput, rightInput := ctx.Fork2(input)
+	return effect(leftInput, lfoLeft), effect(rightInput, lfoRight)
+}
+
+// Tremolo modulates the amplitude of 'input'. 'depth' ranges from 0 (no
+// effect) to 1 (the signal is silenced at the troughs of the LFO).
+func Tremolo(ctx sound.Context, input, lfoInput chan float64, depth float64) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		for x := range input {
+			lfo := <-lfoInput
+			output <- x * (1 - depth*(1-lfo)/2)
+		}
+	}()
+
+	return output
+}
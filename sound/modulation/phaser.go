@@ -0,0 +1,67 @@
+package modulation
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"math"
+)
+
+// PhaserParams controls Phaser.
+type PhaserParams struct {
+	// Number of first-order all-pass stages. Each pair of stages adds a notch.
+	Stages int
+
+	// Range of the break frequency of the all-pass stages, in Hertz.
+	MinFreq float64
+	MaxFreq float64
+
+	// Proportion of the output of the last stage fed back into the first,
+	// from -1 to 1.
+	Feedback float64
+
+	// Proportion of the phase-shifted signal in the output, from 0 (dry) to 1.
+	Mix float64
+}
+
+// DefaultPhaser is a PhaserParams with some suitable values filled in.
+var DefaultPhaser = PhaserParams{
+	Stages:   4,
+	MinFreq:  200.0,
+	MaxFreq:  2000.0,
+	Feedback: 0.5,
+	Mix:      0.5,
+}
+
+// Phaser sweeps notches through the spectrum of 'input' by mixing it with a
+// copy passed through a chain of all-pass stages, whose break frequency is
+// modulated exponentially between MinFreq and MaxFreq by 'lfoInput'.
+func Phaser(ctx sound.Context, input, lfoInput chan float64, p PhaserParams) (output chan float64) {
+	output = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(output)
+
+		prevInputs := make([]float64, p.Stages)
+		prevOutputs := make([]float64, p.Stages)
+		last := 0.0
+
+		for x := range input {
+			lfo := <-lfoInput
+			freq := p.MinFreq * math.Pow(p.MaxFreq/p.MinFreq, (lfo+1)/2)
+			t := math.Tan(math.Pi * freq / ctx.SampleRate)
+			a := (t - 1) / (t + 1)
+
+			y := x + p.Feedback*last
+			for i := 0; i < p.Stages; i++ {
+				z := a*y + prevInputs[i] - a*prevOutputs[i]
+				prevInputs[i] = y
+				prevOutputs[i] = z
+				y = z
+			}
+			last = y
+
+			output <- x*(1-p.Mix) + y*p.Mix
+		}
+	}()
+
+	return output
+}
@@ -0,0 +1,168 @@
+package onset
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"time"
+)
+
+// EstimateTempo estimates the tempo (in beats per minute, within
+// [minBPM, maxBPM]) of an ODF with the given frame rate, by finding the
+// strongest periodicity in its autocorrelation. Periodicities are weighted
+// towards 120 BPM to resolve octave ambiguity.
+func EstimateTempo(odf []float64, frameRate float64, minBPM, maxBPM float64) (bpm float64) {
+	if len(odf) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, x := range odf {
+		mean += x
+	}
+	mean /= float64(len(odf))
+
+	// Autocorrelation via the FFT, zero-padded to avoid wrap-around.
+	padded := make([]float64, 2*len(odf))
+	for i, x := range odf {
+		padded[i] = x - mean
+	}
+	spectrum := fft.FFT(padded)
+	for i, c := range spectrum {
+		spectrum[i] = complex(real(c)*real(c)+imag(c)*imag(c), 0)
+	}
+	r := fft.IFFT(spectrum)
+
+	minLag := int(math.Floor(60 * frameRate / maxBPM))
+	maxLag := int(math.Ceil(60 * frameRate / minBPM))
+	if minLag < 1 {
+		minLag = 1
+	}
+	if maxLag >= len(odf)-1 {
+		maxLag = len(odf) - 2
+	}
+
+	weighted := make([]float64, maxLag+2)
+	best := -1
+	for lag := minLag - 1; lag <= maxLag+1; lag++ {
+		if lag < 1 {
+			continue
+		}
+		b := 60 * frameRate / float64(lag)
+		octaves := math.Log2(b / 120)
+		weighted[lag] = real(r[lag]) * math.Exp(-0.5*octaves*octaves)
+		if lag >= minLag && lag <= maxLag && (best < 0 || weighted[lag] > weighted[best]) {
+			best = lag
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+
+	// Parabolic interpolation of the peak.
+	lag := float64(best)
+	if best > 1 && best < len(weighted)-1 {
+		a, b, c := weighted[best-1], weighted[best], weighted[best+1]
+		if d := a - 2*b + c; d != 0 {
+			lag += 0.5 * (a - c) / d
+		}
+	}
+
+	return 60 * frameRate / lag
+}
+
+// TrackBeats finds the beat positions (as frame indices) in an ODF that best
+// fit a constant tempo of 'bpm', using dynamic programming.
+// Based on Ellis, "Beat Tracking by Dynamic Programming", Journal of New Music
+// Research, 2007.
+func TrackBeats(odf []float64, frameRate float64, bpm float64) (beats []int) {
+	const tightness = 100.0
+
+	if len(odf) == 0 || bpm <= 0 {
+		return nil
+	}
+
+	period := 60 * frameRate / bpm
+
+	// Normalise the ODF by its standard deviation.
+	mean, sd := 0.0, 0.0
+	for _, x := range odf {
+		mean += x
+	}
+	mean /= float64(len(odf))
+	for _, x := range odf {
+		sd += (x - mean) * (x - mean)
+	}
+	sd = math.Sqrt(sd / float64(len(odf)))
+	if sd == 0 {
+		sd = 1
+	}
+
+	score := make([]float64, len(odf))
+	backlink := make([]int, len(odf))
+
+	for t := range odf {
+		local := odf[t] / sd
+		score[t] = local
+		backlink[t] = -1
+
+		start := t - int(math.Round(2*period))
+		end := t - int(math.Round(period/2))
+		if start < 0 {
+			start = 0
+		}
+
+		best := math.Inf(-1)
+		for p := start; p <= end; p++ {
+			x := math.Log(float64(t-p) / period)
+			s := score[p] - tightness*x*x
+			if s > best {
+				best = s
+				backlink[t] = p
+			}
+		}
+		if backlink[t] >= 0 {
+			score[t] = local + best
+		}
+	}
+
+	// Start from the best scoring frame within the last beat period.
+	last := len(odf) - 1
+	for t := len(odf) - 1 - int(period); t < len(odf); t++ {
+		if t >= 0 && score[t] > score[last] {
+			last = t
+		}
+	}
+
+	for t := last; t >= 0; t = backlink[t] {
+		beats = append(beats, t)
+	}
+
+	// Reverse into chronological order.
+	for i, j := 0, len(beats)-1; i < j; i, j = i+1, j-1 {
+		beats[i], beats[j] = beats[j], beats[i]
+	}
+
+	return beats
+}
+
+// Beats estimates the tempo of a finite input and the times of its beats.
+func Beats(ctx sound.Context, input chan float64, p Params, minBPM, maxBPM float64) (bpm float64, beats []time.Duration) {
+	window := fft.HanningWindow(p.WindowSize)
+	frames := fft.STFT(input, window, p.WindowSize-p.HopSize)
+
+	var odf []float64
+	for x := range SpectralFlux(frames) {
+		odf = append(odf, x)
+	}
+
+	frameRate := FrameRate(ctx, p)
+	offset := frameOffset(ctx, p)
+	bpm = EstimateTempo(odf, frameRate, minBPM, maxBPM)
+
+	for _, frame := range TrackBeats(odf, frameRate, bpm) {
+		beats = append(beats, time.Duration(float64(frame)/frameRate*float64(time.Second))+offset)
+	}
+
+	return bpm, beats
+}
@@ -0,0 +1,228 @@
+// Package onset provides onset detection functions, peak picking, tempo
+// estimation and beat tracking.
+//
+// Onset detection functions (ODFs) are streams with one value per STFT frame,
+// so their rate is the sample rate divided by the hop size.
+package onset
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// Params controls onset detection.
+type Params struct {
+	// STFT window size and the number of samples between frames.
+	WindowSize int
+	HopSize    int
+
+	// Number of frames before and after a candidate peak that are examined
+	// when picking peaks.
+	PreFrames  int
+	PostFrames int
+
+	// A frame is an onset if its ODF value is the maximum within the
+	// examined frames and exceeds Multiplier times their mean plus Delta.
+	Multiplier float64
+	Delta      float64
+
+	// Onsets closer together than this are merged.
+	MinInterval time.Duration
+}
+
+// DefaultParams is a Params suitable for percussive material.
+var DefaultParams = Params{
+	WindowSize:  1024,
+	HopSize:     512,
+	PreFrames:   8,
+	PostFrames:  2,
+	Multiplier:  1.3,
+	Delta:       0.5,
+	MinInterval: time.Millisecond * 50,
+}
+
+// Compression applied to magnitudes, so that detection is less dependent on
+// the level of the input.
+func compress(magnitude float64) float64 {
+	return math.Log(1 + 100*magnitude)
+}
+
+// SpectralFlux produces the sum of the increases in log-compressed magnitude
+// of each bin between consecutive frames. 'frames' are the squared magnitudes
+// produced by fft.STFT.
+func SpectralFlux(frames chan []float64) (odfOutput chan float64) {
+	odfOutput = make(chan float64)
+
+	go func() {
+		defer close(odfOutput)
+
+		var prev []float64
+
+		for frame := range frames {
+			// Only the first half of the spectrum of a real signal is unique.
+			n := len(frame)/2 + 1
+			if prev == nil {
+				prev = make([]float64, n)
+			}
+
+			flux := 0.0
+			for i := 0; i < n; i++ {
+				m := compress(math.Sqrt(frame[i]))
+				flux += math.Max(0, m-prev[i])
+				prev[i] = m
+			}
+
+			odfOutput <- flux
+		}
+	}()
+
+	return odfOutput
+}
+
+// ComplexDomain measures the distance of each bin from the value predicted by
+// extrapolating the magnitude and phase of the previous two frames, which
+// detects soft tonal onsets as well as percussive ones. 'frames' are produced
+// by fft.ComplexSTFT.
+// Based on Bello et al., "On the use of phase and energy for musical onset
+// detection in the complex domain", IEEE Signal Processing Letters, 2004.
+func ComplexDomain(frames chan []complex128) (odfOutput chan float64) {
+	odfOutput = make(chan float64)
+
+	go func() {
+		defer close(odfOutput)
+
+		var prevMags, prevPhases, prevPrevPhases []float64
+
+		for frame := range frames {
+			n := len(frame)/2 + 1
+			if prevMags == nil {
+				prevMags = make([]float64, n)
+				prevPhases = make([]float64, n)
+				prevPrevPhases = make([]float64, n)
+			}
+
+			sum := 0.0
+			for i := 0; i < n; i++ {
+				mag := compress(cmplx.Abs(frame[i]))
+				phase := cmplx.Phase(frame[i])
+
+				target := cmplx.Rect(prevMags[i], 2*prevPhases[i]-prevPrevPhases[i])
+				sum += cmplx.Abs(cmplx.Rect(mag, phase) - target)
+
+				prevMags[i] = mag
+				prevPrevPhases[i] = prevPhases[i]
+				prevPhases[i] = phase
+			}
+
+			odfOutput <- sum / float64(n)
+		}
+	}()
+
+	return odfOutput
+}
+
+// PickPeaks finds onsets in an ODF stream with the given frame rate (in frames
+// per second) and sends their times. Times refer to the start of each frame
+// plus 'offset', which should usually be half the window duration.
+func PickPeaks(odfInput chan float64, frameRate float64, offset time.Duration, p Params) (onsetOutput chan time.Duration) {
+	onsetOutput = make(chan time.Duration)
+
+	go func() {
+		defer close(onsetOutput)
+
+		size := p.PreFrames + p.PostFrames + 1
+		history := make([]float64, size)
+		index := -p.PostFrames // frame number of the candidate
+		var last time.Duration
+		found := false
+
+		examine := func() {
+			if index < 0 {
+				return
+			}
+
+			candidate := history[p.PreFrames]
+			sum := 0.0
+			for _, x := range history {
+				if x > candidate {
+					return
+				}
+				sum += x
+			}
+
+			if candidate <= p.Multiplier*sum/float64(size)+p.Delta {
+				return
+			}
+
+			t := time.Duration(float64(index)/frameRate*float64(time.Second)) + offset
+			if !found || t-last >= p.MinInterval {
+				onsetOutput <- t
+				last = t
+				found = true
+			}
+		}
+
+		for x := range odfInput {
+			copy(history, history[1:])
+			history[size-1] = x
+			examine()
+			index++
+		}
+
+		// Flush the frames that were waiting for their successors.
+		for i := 0; i < p.PostFrames; i++ {
+			copy(history, history[1:])
+			history[size-1] = 0
+			examine()
+			index++
+		}
+	}()
+
+	return onsetOutput
+}
+
+// Onsets detects onsets in 'input' using spectral flux.
+func Onsets(ctx sound.Context, input chan float64, p Params) (onsetOutput chan time.Duration) {
+	window := fft.HanningWindow(p.WindowSize)
+	frames := fft.STFT(input, window, p.WindowSize-p.HopSize)
+	odf := SpectralFlux(frames)
+	return PickPeaks(odf, FrameRate(ctx, p), frameOffset(ctx, p), p)
+}
+
+// FrameRate returns the rate of the ODF streams produced with the given
+// parameters, in frames per second.
+func FrameRate(ctx sound.Context, p Params) float64 {
+	return ctx.SampleRate / float64(p.HopSize)
+}
+
+// Time from the start of a frame to its centre.
+func frameOffset(ctx sound.Context, p Params) time.Duration {
+	return time.Duration(float64(p.WindowSize) / 2 / ctx.SampleRate * float64(time.Second))
+}
+
+// Slice cuts 'buffer' at each onset time, returning the pieces between them.
+// Audio before the first onset is discarded.
+func Slice(ctx sound.Context, buffer []float64, onsets []time.Duration) (slices [][]float64) {
+	for i, onset := range onsets {
+		start := int(ctx.SampleRate * float64(onset) / float64(time.Second))
+		end := len(buffer)
+		if i+1 < len(onsets) {
+			end = int(ctx.SampleRate * float64(onsets[i+1]) / float64(time.Second))
+		}
+
+		if start < 0 {
+			start = 0
+		}
+		if end > len(buffer) {
+			end = len(buffer)
+		}
+		if start < end {
+			slices = append(slices, buffer[start:end])
+		}
+	}
+
+	return slices
+}
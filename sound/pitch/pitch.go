@@ -0,0 +1,307 @@
+// Package pitch provides monophonic pitch trackers.
+//
+// The trackers produce a frequency stream and a confidence stream with one
+// value per input sample, so that the frequency can drive an oscillator
+// directly. A new estimate is made every HopSize samples and held until the
+// next. While the input is unvoiced (confidence is low) the last voiced
+// frequency is held.
+//
+// Note that both streams must be read from, even if only the frequency is
+// wanted; sound.Context.Drain can be used on the confidence stream.
+package pitch
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/fft"
+	"math"
+)
+
+// Params controls a pitch tracker.
+type Params struct {
+	// Number of samples compared at each lag.
+	WindowSize int
+
+	// Number of samples between estimates.
+	HopSize int
+
+	// Range of frequencies to search, in Hertz.
+	MinFreq float64
+	MaxFreq float64
+
+	// Estimates with a confidence below this are treated as unvoiced.
+	MinConfidence float64
+}
+
+// DefaultParams is a Params suitable for voice and most instruments.
+var DefaultParams = Params{
+	WindowSize:    1024,
+	HopSize:       256,
+	MinFreq:       60.0,
+	MaxFreq:       1200.0,
+	MinConfidence: 0.85,
+}
+
+// An estimator finds the period of a frame, returning the lag in samples
+// (possibly fractional) and a confidence between 0 and 1.
+type estimator func(f *frame) (lag float64, confidence float64)
+
+// YIN tracks pitch using the YIN algorithm, which looks for the lag that
+// minimises the cumulative mean normalised difference of the signal with
+// itself.
+// Based on de Cheveigné & Kawahara, "YIN, a fundamental frequency estimator
+// for speech and music", JASA 2002.
+// Both outputs must be read from (see the package documentation).
+func YIN(ctx sound.Context, input chan float64, p Params) (freqOutput, confidenceOutput chan float64) {
+	return track(ctx, input, p, yin)
+}
+
+// Autocorrelation tracks pitch by finding the lag with the highest normalised
+// autocorrelation. Both outputs must be read from (see the package
+// documentation).
+func Autocorrelation(ctx sound.Context, input chan float64, p Params) (freqOutput, confidenceOutput chan float64) {
+	return track(ctx, input, p, autocorrelation)
+}
+
+func track(ctx sound.Context, input chan float64, p Params, est estimator) (freqOutput, confidenceOutput chan float64) {
+	freqOutput = make(chan float64, ctx.StreamBufferSize)
+	confidenceOutput = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(freqOutput)
+		defer close(confidenceOutput)
+
+		minLag := int(math.Floor(ctx.SampleRate / p.MaxFreq))
+		maxLag := int(math.Ceil(ctx.SampleRate / p.MinFreq))
+		if minLag < 2 {
+			minLag = 2
+		}
+
+		f := newFrame(p.WindowSize, minLag, maxLag)
+		freq := 0.0
+		confidence := 0.0
+		count := 0
+
+		for x := range input {
+			f.push(x)
+			freqOutput <- freq
+			confidenceOutput <- confidence
+
+			count++
+			if count < p.HopSize {
+				continue
+			}
+			count = 0
+
+			lag, c := est(f)
+			confidence = c
+			if c >= p.MinConfidence && lag > 0 {
+				freq = ctx.SampleRate / lag
+			}
+		}
+	}()
+
+	return freqOutput, confidenceOutput
+}
+
+// The most recent samples of the input, with helpers to compute the
+// correlation terms that the estimators need.
+type frame struct {
+	windowSize     int
+	minLag, maxLag int
+	ring           []float64
+	pos            int       // index of the oldest sample in ring
+	samples        []float64 // ring, oldest first, filled by correlate
+}
+
+func newFrame(windowSize, minLag, maxLag int) (f *frame) {
+	return &frame{
+		windowSize: windowSize,
+		minLag:     minLag,
+		maxLag:     maxLag,
+		ring:       make([]float64, windowSize+maxLag+1),
+		samples:    make([]float64, windowSize+maxLag+1),
+	}
+}
+
+func (f *frame) push(x float64) {
+	f.ring[f.pos] = x
+	f.pos = (f.pos + 1) % len(f.ring)
+}
+
+// Returns r(tau) = sum_{j<W} x_j x_{j+tau} for tau in [0, maxLag], computed
+// with the FFT, and prefix sums of x^2.
+func (f *frame) correlate() (r []float64, energy []float64) {
+	n := copy(f.samples, f.ring[f.pos:])
+	copy(f.samples[n:], f.ring[:f.pos])
+	n = len(f.samples)
+
+	window := make([]float64, n)
+	copy(window, f.samples[:f.windowSize])
+
+	a := fft.FFT(window)
+	b := fft.FFT(f.samples)
+
+	for i := range a {
+		a[i] = complex(real(a[i]), -imag(a[i])) * b[i]
+	}
+
+	c := fft.IFFT(a)
+	r = make([]float64, f.maxLag+1)
+	for tau := range r {
+		r[tau] = real(c[tau])
+	}
+
+	energy = make([]float64, n+1)
+	for i, x := range f.samples {
+		energy[i+1] = energy[i] + x*x
+	}
+
+	return r, energy
+}
+
+// Sum of x^2 over the window starting at 'tau'.
+func windowEnergy(energy []float64, tau, windowSize int) float64 {
+	return energy[tau+windowSize] - energy[tau]
+}
+
+func yin(f *frame) (lag float64, confidence float64) {
+	const threshold = 0.15
+
+	r, energy := f.correlate()
+	e0 := windowEnergy(energy, 0, f.windowSize)
+	if e0 == 0 {
+		return 0, 0
+	}
+
+	// Cumulative mean normalised difference function.
+	d := make([]float64, f.maxLag+1)
+	d[0] = 1
+	sum := 0.0
+	for tau := 1; tau <= f.maxLag; tau++ {
+		diff := e0 + windowEnergy(energy, tau, f.windowSize) - 2*r[tau]
+		sum += diff
+		if sum > 0 {
+			d[tau] = diff * float64(tau) / sum
+		} else {
+			d[tau] = 1
+		}
+	}
+
+	// Take the first dip below the threshold, or the global minimum if there
+	// is none.
+	best := -1
+	for tau := f.minLag; tau <= f.maxLag; tau++ {
+		if d[tau] < threshold {
+			for tau+1 <= f.maxLag && d[tau+1] < d[tau] {
+				tau++
+			}
+			best = tau
+			break
+		}
+	}
+	if best < 0 {
+		best = f.minLag
+		for tau := f.minLag; tau <= f.maxLag; tau++ {
+			if d[tau] < d[best] {
+				best = tau
+			}
+		}
+	}
+
+	return interpolate(d, best), math.Max(0, math.Min(1, 1-d[best]))
+}
+
+func autocorrelation(f *frame) (lag float64, confidence float64) {
+	r, energy := f.correlate()
+	e0 := windowEnergy(energy, 0, f.windowSize)
+	if e0 == 0 {
+		return 0, 0
+	}
+
+	nr := make([]float64, f.maxLag+1)
+	for tau := range nr {
+		norm := math.Sqrt(e0 * windowEnergy(energy, tau, f.windowSize))
+		if norm > 0 {
+			nr[tau] = r[tau] / norm
+		}
+	}
+
+	// Skip past the peak at zero lag before looking for the maximum.
+	start := f.minLag
+	for start < f.maxLag && nr[start+1] < nr[start] {
+		start++
+	}
+
+	max := start
+	for tau := start; tau <= f.maxLag; tau++ {
+		if nr[tau] > nr[max] {
+			max = tau
+		}
+	}
+
+	// Multiples of the period correlate almost as well as the period itself,
+	// so take the first peak that comes close to the maximum.
+	best := max
+	for tau := start; tau < max; tau++ {
+		if nr[tau] >= 0.9*nr[max] && nr[tau] >= nr[tau-1] && nr[tau] >= nr[tau+1] {
+			best = tau
+			break
+		}
+	}
+
+	// Interpolate the peak by interpolating the minimum of its negation.
+	neg := make([]float64, len(nr))
+	for i, x := range nr {
+		neg[i] = -x
+	}
+
+	return interpolate(neg, best), math.Max(0, math.Min(1, nr[best]))
+}
+
+// Refine the position of a minimum of 'd' at 'i' by fitting a parabola.
+func interpolate(d []float64, i int) float64 {
+	if i <= 0 || i >= len(d)-1 {
+		return float64(i)
+	}
+
+	a, b, c := d[i-1], d[i], d[i+1]
+	denom := a - 2*b + c
+	if denom == 0 {
+		return float64(i)
+	}
+
+	return float64(i) + 0.5*(a-c)/denom
+}
+
+// Quantise snaps each frequency received from 'freqInput' to the frequency of
+// the nearest note.
+func Quantise(ctx sound.Context, freqInput chan float64) (freqOutput chan float64) {
+	return ctx.Map(freqInput, func(freq float64) float64 {
+		if freq <= 0 {
+			return freq
+		}
+		return music.FromFrequency(freq).Frequency()
+	})
+}
+
+// Notes converts a frequency and confidence stream from a tracker into a
+// stream of notes. The previous note is repeated while the confidence is
+// below 'minConfidence'.
+func Notes(ctx sound.Context, freqInput, confidenceInput chan float64, minConfidence float64) (noteOutput chan music.Note) {
+	noteOutput = make(chan music.Note, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(noteOutput)
+
+		var note music.Note
+		for freq := range freqInput {
+			if <-confidenceInput >= minConfidence && freq > 0 {
+				note = music.FromFrequency(freq)
+			}
+			noteOutput <- note
+		}
+	}()
+
+	return noteOutput
+}
@@ -0,0 +1,168 @@
+// Package reverb provides algorithmic reverberation.
+package reverb
+
+import (
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/delay"
+	"time"
+)
+
+// Params controls the character of a reverb.
+type Params struct {
+	// Size of the simulated room, from 0 to 1. Larger rooms decay more slowly.
+	RoomSize float64
+
+	// High frequency absorption, from 0 (bright) to 1 (dull).
+	Damping float64
+
+	// Time between the dry signal and the onset of the reverb.
+	PreDelay time.Duration
+
+	// Stereo width of the reverb, from 0 (mono) to 1.
+	Width float64
+
+	// Output gains of the reverberated and unprocessed signals.
+	Wet float64
+	Dry float64
+}
+
+// DefaultParams is a Params with some suitable values filled in.
+var DefaultParams = Params{
+	RoomSize: 0.5,
+	Damping:  0.5,
+	PreDelay: 0,
+	Width:    1.0,
+	Wet:      0.3,
+	Dry:      1.0,
+}
+
+// Tunings of the original Freeverb, in samples at 44.1 kHz.
+var (
+	combTunings    = []float64{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	allPassTunings = []float64{556, 441, 341, 225}
+)
+
+const (
+	stereoSpread    = 23
+	fixedGain       = 0.015
+	allPassFeedback = 0.5
+)
+
+// A lowpass-feedback comb filter.
+type comb struct {
+	line     *delay.Line
+	length   int
+	filtered float64
+}
+
+func (c *comb) process(x, feedback, damp float64) (y float64) {
+	y = c.line.At(c.length - 1)
+	c.filtered = y*(1-damp) + c.filtered*damp
+	c.line.Push(x + c.filtered*feedback)
+	return y
+}
+
+// Freeverb's approximation of a Schroeder all-pass.
+type allPass struct {
+	line   *delay.Line
+	length int
+}
+
+func (a *allPass) process(x float64) (y float64) {
+	delayed := a.line.At(a.length - 1)
+	a.line.Push(x + delayed*allPassFeedback)
+	return delayed - x
+}
+
+// One channel's worth of filters.
+type tank struct {
+	combs     []comb
+	allPasses []allPass
+}
+
+func newTank(ctx sound.Context, spread float64) (t *tank) {
+	scale := ctx.SampleRate / 44100.0
+	t = &tank{
+		combs:     make([]comb, len(combTunings)),
+		allPasses: make([]allPass, len(allPassTunings)),
+	}
+
+	for i, tuning := range combTunings {
+		length := int((tuning+spread)*scale + 0.5)
+		t.combs[i] = comb{line: delay.NewLine(length), length: length}
+	}
+
+	for i, tuning := range allPassTunings {
+		length := int((tuning+spread)*scale + 0.5)
+		t.allPasses[i] = allPass{line: delay.NewLine(length), length: length}
+	}
+
+	return t
+}
+
+func (t *tank) process(x, feedback, damp float64) (y float64) {
+	for i := range t.combs {
+		y += t.combs[i].process(x, feedback, damp)
+	}
+
+	for i := range t.allPasses {
+		y = t.allPasses[i].process(y)
+	}
+
+	return y
+}
+
+// Freeverb applies a stereo Schroeder-Moorer reverb, based on Jezar's public
+// domain Freeverb. Output stops when either input is closed, and the rest of
+// the other input is discarded; pad the inputs (see sound.Context.Pad) to hear
+// the tail of the reverb.
+func Freeverb(ctx sound.Context, leftInput, rightInput chan float64, p Params) (leftOutput, rightOutput chan float64) {
+	leftOutput = make(chan float64, ctx.StreamBufferSize)
+	rightOutput = make(chan float64, ctx.StreamBufferSize)
+
+	go func() {
+		defer close(leftOutput)
+		defer close(rightOutput)
+
+		feedback := p.RoomSize*0.28 + 0.7
+		damp := p.Damping * 0.4
+		wet1 := p.Wet * (p.Width/2 + 0.5)
+		wet2 := p.Wet * ((1 - p.Width) / 2)
+
+		leftTank := newTank(ctx, 0)
+		rightTank := newTank(ctx, stereoSpread)
+
+		preDelay := int(ctx.SampleRate * float64(p.PreDelay) / float64(time.Second))
+		preDelayLine := delay.NewLine(preDelay)
+
+		// Discard whatever remains of the longer input, so its producer is not
+		// left blocked.
+		defer ctx.Drain(rightInput)
+		defer ctx.Drain(leftInput)
+
+		for l := range leftInput {
+			r, ok := <-rightInput
+			if !ok {
+				return
+			}
+
+			preDelayLine.Push((l + r) * fixedGain)
+			x := preDelayLine.At(preDelay)
+
+			accL := leftTank.process(x, feedback, damp)
+			accR := rightTank.process(x, feedback, damp)
+
+			leftOutput <- accL*wet1 + accR*wet2 + l*p.Dry
+			rightOutput <- accR*wet1 + accL*wet2 + r*p.Dry
+		}
+	}()
+
+	return leftOutput, rightOutput
+}
+
+// FreeverbMono applies Freeverb to a mono input such as the output of a
+// Sequencer, producing a stereo result.
+func FreeverbMono(ctx sound.Context, input chan float64, p Params) (leftOutput, rightOutput chan float64) {
+	left, right := ctx.Fork2(input)
+	return Freeverb(ctx, left, right, p)
+}
@@ -1,6 +1,8 @@
 package sound
 
 import (
+	"fmt"
+	"github.com/kierdavis/gosound/music"
 	"sort"
 	"sync"
 	"time"
@@ -22,6 +24,12 @@ func (ds durationSlice) Swap(i, j int) {
 
 type Sequencer struct {
 	Ctx Context
+	
+	// Tempo in beats per minute, used by Beat, and the key of the piece. These
+	// are not needed to play the parts, but can be recorded in rendered files.
+	Tempo float64
+	Key *music.Scale
+	
 	offsets []time.Duration
 	parts map[time.Duration][]chan float64
 	sync.Mutex
@@ -35,6 +43,15 @@ func NewSequencer(ctx Context) (seq *Sequencer) {
 	}
 }
 
+// Beat returns the offset of beat 'n', counting from zero, at the sequencer's
+// tempo. It panics if Tempo has not been set to a positive value.
+func (seq *Sequencer) Beat(n float64) (offset time.Duration) {
+	if !(seq.Tempo > 0) {
+		panic(fmt.Sprintf("Sequencer.Beat: invalid tempo %v", seq.Tempo))
+	}
+	return time.Duration(n * 60 / seq.Tempo * float64(time.Second))
+}
+
 func (seq *Sequencer) Add(offset time.Duration, stream chan float64) {
 	seq.Lock()
 	seq.offsets = append(seq.offsets, offset)
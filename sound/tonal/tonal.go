@@ -0,0 +1,176 @@
+// Package tonal estimates the key and chords of audio from chroma vectors,
+// such as those in features.Frame.Chroma.
+package tonal
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"math"
+	"time"
+)
+
+// Key profiles from Krumhansl & Kessler, "Tracing the dynamic changes in
+// perceived tonal organization in a spatial representation of musical keys",
+// Psychological Review, 1982. Index 0 is the tonic.
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// DetectKey estimates the key of a piece from the chroma vectors of its
+// frames, by correlating their sum with the major and minor key profiles in
+// every transposition. The root of the returned scale is in octave 4.
+// 'correlation' (from -1 to 1) indicates how well the best key fits.
+func DetectKey(chromas [][12]float64) (key music.Scale, correlation float64) {
+	var total [12]float64
+	for _, chroma := range chromas {
+		for i, x := range chroma {
+			total[i] += x
+		}
+	}
+
+	correlation = math.Inf(-1)
+	for root := 0; root < 12; root++ {
+		for _, mode := range []struct {
+			profile   [12]float64
+			intervals []int
+		}{
+			{majorProfile, music.Major},
+			{minorProfile, music.Minor},
+		} {
+			var rotated [12]float64
+			for i := range rotated {
+				rotated[(root+i)%12] = mode.profile[i]
+			}
+
+			r := pearson(total, rotated)
+			if r > correlation {
+				correlation = r
+				key = music.Scale{
+					Root:      music.MakeNote(music.NoteLetter(root), 4),
+					Intervals: mode.intervals,
+				}
+			}
+		}
+	}
+
+	return key, correlation
+}
+
+func pearson(a, b [12]float64) float64 {
+	meanA, meanB := 0.0, 0.0
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= 12
+	meanB /= 12
+
+	cov, varA, varB := 0.0, 0.0, 0.0
+	for i := range a {
+		cov += (a[i] - meanA) * (b[i] - meanB)
+		varA += (a[i] - meanA) * (a[i] - meanA)
+		varB += (b[i] - meanB) * (b[i] - meanB)
+	}
+
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// Qualities considered by RecogniseChord.
+var chordQualities = []music.ChordQuality{
+	music.MajorTriad,
+	music.MinorTriad,
+	music.DiminishedTriad,
+	music.AugmentedTriad,
+	music.DominantSeventh,
+	music.MajorSeventh,
+	music.MinorSeventh,
+}
+
+// RecogniseChord finds the chord whose template best matches a chroma vector
+// by cosine similarity. Silent frames, or those matching no chord better
+// than 'minSimilarity', give music.NoChord.
+func RecogniseChord(chroma [12]float64, minSimilarity float64) (chord music.Chord, similarity float64) {
+	norm := 0.0
+	for _, x := range chroma {
+		norm += x * x
+	}
+	if norm == 0 {
+		return music.Chord{}, 0
+	}
+	norm = math.Sqrt(norm)
+
+	for _, quality := range chordQualities {
+		intervals := quality.Intervals()
+
+		for root := 0; root < 12; root++ {
+			dot := 0.0
+			for _, i := range intervals {
+				dot += chroma[(root+i)%12]
+			}
+
+			// Prefer triads to sevenths with the same score by penalising
+			// extra notes slightly, via the template norm.
+			s := dot / (norm * math.Sqrt(float64(len(intervals))))
+			if s > similarity {
+				similarity = s
+				chord = music.Chord{Root: music.NoteLetter(root), Quality: quality}
+			}
+		}
+	}
+
+	if similarity < minSimilarity {
+		return music.Chord{}, similarity
+	}
+	return chord, similarity
+}
+
+// RecogniseChords applies RecogniseChord to each chroma vector received from
+// 'chromaInput'.
+func RecogniseChords(chromaInput chan [12]float64, minSimilarity float64) (chordOutput chan music.Chord) {
+	chordOutput = make(chan music.Chord)
+
+	go func() {
+		defer close(chordOutput)
+
+		for chroma := range chromaInput {
+			chord, _ := RecogniseChord(chroma, minSimilarity)
+			chordOutput <- chord
+		}
+	}()
+
+	return chordOutput
+}
+
+// A Segment is a span of time over which the chord is constant.
+type Segment struct {
+	Start, End time.Duration
+	Chord      music.Chord
+}
+
+// Timeline merges a sequence of per-frame chords, spaced 'frameDuration'
+// apart, into segments. Runs shorter than 'minFrames' frames are absorbed into
+// the preceding segment to suppress flicker.
+func Timeline(chords []music.Chord, frameDuration time.Duration, minFrames int) (segments []Segment) {
+	for i := 0; i < len(chords); {
+		j := i + 1
+		for j < len(chords) && chords[j] == chords[i] {
+			j++
+		}
+
+		start := time.Duration(i) * frameDuration
+		end := time.Duration(j) * frameDuration
+
+		if len(segments) > 0 && (j-i < minFrames || segments[len(segments)-1].Chord == chords[i]) {
+			segments[len(segments)-1].End = end
+		} else {
+			segments = append(segments, Segment{Start: start, End: end, Chord: chords[i]})
+		}
+
+		i = j
+	}
+
+	return segments
+}
@@ -0,0 +1,389 @@
+// Package aiffio reads and writes AIFF and AIFF-C files without cgo.
+//
+// Big-endian PCM (8 to 32 bit), little-endian 'sowt' PCM and 32/64-bit float
+// data are supported. Markers and the INST chunk's loop points are preserved
+// by ReadHeader and Output, which also map them, the text chunks and an Apple
+// Loops basc chunk to soundio.Metadata. The exact tempo is kept in a private
+// APPL chunk that only this package understands.
+package aiffio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+)
+
+type Input struct {
+	Filename   string
+	BufferSize int
+
+	// Part of the file to read. The zero Region reads the whole file once.
+	Region soundio.Region
+}
+
+// Info reads the format and length of the file without streaming it.
+func (si Input) Info() (info soundio.FileInfo, err error) {
+	h, err := ReadHeader(si.Filename)
+	if err != nil {
+		return info, err
+	}
+
+	container := "AIFF"
+	if h.Compression != "NONE" {
+		container = "AIFF-C " + h.Compression
+	}
+
+	return soundio.FileInfo{
+		SampleRate: h.SampleRate,
+		Channels:   h.Channels,
+		Frames:     h.Frames,
+		Format:     container + " " + h.Format.String(),
+	}, nil
+}
+
+// Metadata reads the metadata of the file without streaming it.
+func (si Input) Metadata() (md soundio.Metadata, err error) {
+	h, err := ReadHeader(si.Filename)
+	return h.Metadata, err
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	h, err := readHeader(f)
+	if err != nil {
+		f.Close()
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	channels = make([]chan float64, h.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			err2 := f.Close()
+			if err2 != nil {
+				errChan <- err2
+			}
+
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		frameSize := int64(h.Channels * h.Format.Size())
+		start, end := si.Region.Bounds(h.Frames)
+		if start == end {
+			return
+		}
+
+		region := h
+		region.dataSize = (end - start) * frameSize
+
+		err := si.Region.Repeat(func() (frames int64, err error) {
+			_, err = f.Seek(h.dataOffset+start*frameSize, io.SeekStart)
+			if err != nil {
+				return 0, err
+			}
+			return readSamples(bufio.NewReader(f), region, channels, si.BufferSize)
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return h.SampleRate, channels, errChan
+}
+
+// Read the sample data described by 'h' from 'r' and distribute it to
+// 'channels', returning the number of frames read.
+func readSamples(r io.Reader, h Header, channels []chan float64, bufferSize int) (frames int64, err error) {
+	if bufferSize <= 0 {
+		bufferSize = soundio.DefaultBufferSize
+	}
+
+	sampleSize := h.Format.Size()
+	frameSize := sampleSize * h.Channels
+	byteBuffer := make([]byte, frameSize*bufferSize)
+	buffer := make([]float64, h.Channels*bufferSize)
+	remaining := h.dataSize
+
+	for remaining > 0 {
+		chunk := byteBuffer
+		if int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// Tolerate truncated files.
+			err = nil
+			remaining = 0
+		} else if err != nil {
+			return frames, err
+		}
+		remaining -= int64(n)
+
+		n -= n % frameSize
+		for i := 0; i < n/sampleSize; i++ {
+			buffer[i] = h.Format.Decode(byteBuffer[i*sampleSize:], h.ByteOrder)
+		}
+		soundio.Deinterlace(buffer[:n/sampleSize], channels)
+		frames += int64(n / frameSize)
+	}
+
+	return frames, nil
+}
+
+type Output struct {
+	Filename   string
+	Format     soundio.SampleFormat
+	BufferSize int
+
+	// Dither applied when converting to an integer format.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	// Write little-endian samples, as an AIFF-C 'sowt' file. Float formats
+	// are always big-endian.
+	LittleEndian bool
+
+	Markers    []Marker
+	Instrument *Instrument // if nil, no INST chunk is written
+
+	// Metadata to write. The title, artist and comment are written in NAME,
+	// AUTH and ANNO chunks, and the markers in addition to Markers. Up to two
+	// loops are written as the sustain and release loops of an INST chunk,
+	// unless Instrument is given. The tempo and key are written in an Apple
+	// Loops basc chunk, which stores the tempo as a whole number of beats. The
+	// exact tempo is also written in an APPL chunk private to gosound, which
+	// only this package reads; other software sees only the basc tempo.
+	Metadata soundio.Metadata
+}
+
+func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	// 8-bit AIFF data is always signed.
+	if so.Format == soundio.Uint8 {
+		so.Format = soundio.Int8
+	}
+
+	compression := ""
+	order := binary.ByteOrder(binary.BigEndian)
+	switch {
+	case so.Format == soundio.Float32:
+		compression = "fl32"
+	case so.Format == soundio.Float64:
+		compression = "fl64"
+	case so.LittleEndian:
+		compression = "sowt"
+		order = binary.LittleEndian
+	}
+
+	f, err := os.Create(so.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := new(bytes.Buffer)
+	header.WriteString("FORM")
+	writeUint32(header, 0)
+	if compression == "" {
+		header.WriteString("AIFF")
+	} else {
+		header.WriteString("AIFC")
+		// Timestamp of the AIFF-C version in use.
+		writeChunk(header, "FVER", []byte{0xA2, 0x80, 0x51, 0x40})
+	}
+
+	commOffset := int64(header.Len())
+	writeChunk(header, "COMM", commonChunk(so.Format, len(channels), sampleRate, compression))
+	writeTexts(header, so.Metadata)
+	writeTempo(header, so.Metadata)
+	markers, inst := mergeMetadata(so.Markers, so.Instrument, so.Metadata)
+	writeMetadata(header, markers, inst)
+
+	header.WriteString("SSND")
+	writeUint32(header, 0)
+	writeUint32(header, 0) // offset
+	writeUint32(header, 0) // block size
+	dataOffset := int64(header.Len())
+
+	w := bufio.NewWriter(f)
+	_, err = w.Write(header.Bytes())
+	if err != nil {
+		return err
+	}
+
+	sampleSize := so.Format.Size()
+	dataSize := int64(0)
+	q := soundio.NewQuantizer(so.Format, so.Dither, len(channels))
+
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	byteBuffer := make([]byte, len(channels)*il.BufferSize*sampleSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		q.Encode(byteBuffer, buffer, order)
+
+		_, err = w.Write(byteBuffer[:len(buffer)*sampleSize])
+		if err != nil {
+			return err
+		}
+		dataSize += int64(len(buffer) * sampleSize)
+		il.Release(buffer)
+	}
+
+	err = il.Err()
+	if err != nil {
+		return err
+	}
+
+	if dataSize%2 == 1 {
+		w.WriteByte(0)
+	}
+
+	frames := dataSize / int64(len(channels)*sampleSize)
+	trailer := new(bytes.Buffer)
+	if so.Metadata.BPM > 0 || so.Metadata.Key != nil {
+		writeChunk(trailer, "basc", bascChunk(so.Metadata, sampleRate, frames, len(so.Metadata.Loops) > 0))
+	}
+	_, err = w.Write(trailer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	formSize := dataOffset + dataSize + dataSize%2 + int64(trailer.Len()) - 8
+
+	err = writeAt(f, 4, uint32Bytes(uint32(formSize)))
+	if err != nil {
+		return err
+	}
+	err = writeAt(f, commOffset+8+2, uint32Bytes(uint32(frames)))
+	if err != nil {
+		return err
+	}
+	return writeAt(f, dataOffset-12, uint32Bytes(uint32(dataSize+8)))
+}
+
+func writeAt(f io.WriteSeeker, offset int64, data []byte) (err error) {
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Body of the COMM chunk, with the frame count left as zero. If
+// 'compression' is empty, a plain AIFF chunk is produced.
+func commonChunk(format soundio.SampleFormat, numChannels int, sampleRate float64, compression string) (body []byte) {
+	buf := new(bytes.Buffer)
+	writeUint16(buf, uint16(numChannels))
+	writeUint32(buf, 0)
+	writeUint16(buf, uint16(format.Bits()))
+
+	var rate [10]byte
+	writeExtended(rate[:], sampleRate)
+	buf.Write(rate[:])
+
+	if compression != "" {
+		buf.WriteString(compression)
+		writePString(buf, compressionNames[compression])
+	}
+
+	return buf.Bytes()
+}
+
+var compressionNames = map[string]string{
+	"fl32": "32-bit floating point",
+	"fl64": "64-bit floating point",
+	"sowt": "",
+}
+
+// Write the MARK and INST chunks.
+func writeMetadata(buf *bytes.Buffer, markers []Marker, inst *Instrument) {
+	if len(markers) > 0 {
+		mark := new(bytes.Buffer)
+		writeUint16(mark, uint16(len(markers)))
+		for _, m := range markers {
+			writeUint16(mark, uint16(m.ID))
+			writeUint32(mark, m.Position)
+			writePString(mark, m.Name)
+		}
+		writeChunk(buf, "MARK", mark.Bytes())
+	}
+
+	if inst != nil {
+		body := new(bytes.Buffer)
+		body.Write([]byte{
+			byte(inst.BaseNote), byte(inst.Detune),
+			byte(inst.LowNote), byte(inst.HighNote),
+			byte(inst.LowVelocity), byte(inst.HighVelocity),
+		})
+		writeUint16(body, uint16(inst.Gain))
+		for _, loop := range []Loop{inst.SustainLoop, inst.ReleaseLoop} {
+			writeUint16(body, uint16(loop.PlayMode))
+			writeUint16(body, uint16(loop.Begin))
+			writeUint16(body, uint16(loop.End))
+		}
+		writeChunk(buf, "INST", body.Bytes())
+	}
+}
+
+// Write a Pascal string, padded to an even total length.
+func writePString(buf *bytes.Buffer, s string) {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+	if len(s)%2 == 0 {
+		buf.WriteByte(0)
+	}
+}
+
+func writeChunk(buf *bytes.Buffer, id string, body []byte) {
+	buf.WriteString(id)
+	writeUint32(buf, uint32(len(body)))
+	buf.Write(body)
+	if len(body)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func writeUint16(buf *bytes.Buffer, x uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], x)
+	buf.Write(b[:])
+}
+
+func writeUint32(buf *bytes.Buffer, x uint32) {
+	buf.Write(uint32Bytes(x))
+}
+
+func uint32Bytes(x uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], x)
+	return b[:]
+}
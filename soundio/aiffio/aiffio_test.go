@@ -0,0 +1,170 @@
+package aiffio
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Two channels of tones at different frequencies.
+func tones(frames int) memio.Input {
+	channels := [][]float64{make([]float64, frames), make([]float64, frames)}
+	for i := 0; i < frames; i++ {
+		channels[0][i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		channels[1][i] = 0.25 * math.Sin(2*math.Pi*1000*float64(i)/44100)
+	}
+	return memio.Input{SampleRate: 44100, Channels: channels, BufferSize: 64}
+}
+
+func checkSamples(t *testing.T, name string, got *memio.Output, want memio.Input, tolerance float64) {
+	if got.SampleRate != want.SampleRate {
+		t.Errorf("%s: got sample rate %v, want %v", name, got.SampleRate, want.SampleRate)
+	}
+	if len(got.Channels) != len(want.Channels) {
+		t.Fatalf("%s: got %d channels, want %d", name, len(got.Channels), len(want.Channels))
+	}
+	for c, buffer := range want.Channels {
+		if len(got.Channels[c]) != len(buffer) {
+			t.Fatalf("%s: channel %d has %d samples, want %d", name, c, len(got.Channels[c]), len(buffer))
+		}
+		for i, x := range buffer {
+			if math.Abs(got.Channels[c][i]-x) > tolerance {
+				t.Errorf("%s: channel %d sample %d is %v, want %v", name, c, i, got.Channels[c][i], x)
+				break
+			}
+		}
+	}
+}
+
+func tempFile(t *testing.T) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "aiffio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "test.aiff"), func() { os.RemoveAll(dir) }
+}
+
+func TestRoundTrip(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	for _, format := range []soundio.SampleFormat{soundio.Int8, soundio.Int16, soundio.Int24, soundio.Int32, soundio.Float32, soundio.Float64} {
+		for _, littleEndian := range []bool{false, true} {
+			in := tones(1001)
+			so := Output{Filename: filename, Format: format, BufferSize: 64, LittleEndian: littleEndian}
+			if err := soundio.Copy(so, in); err != nil {
+				t.Fatal(err)
+			}
+
+			out := new(memio.Output)
+			if err := soundio.Copy(out, Input{Filename: filename, BufferSize: 64}); err != nil {
+				t.Fatal(err)
+			}
+
+			tolerance := 1e-7
+			if !format.IsFloat() {
+				tolerance = 1 / math.Pow(2, float64(format.Bits()-1))
+			}
+			checkSamples(t, format.String(), out, in, tolerance)
+		}
+	}
+}
+
+// Reading with a zero buffer size must still deliver every frame.
+func TestZeroBufferSize(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	in := tones(1001)
+	if err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16, BufferSize: 64}, in); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(memio.Output)
+	done := make(chan error)
+	go func() {
+		done <- soundio.Copy(out, Input{Filename: filename})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reading with a zero buffer size did not finish")
+	}
+	checkSamples(t, "zero buffer size", out, in, 1.0/32768)
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	key := music.Scale{Root: music.MakeNote(music.BFlat, 4), Intervals: music.Minor}
+	md := soundio.Metadata{
+		Title:   "Title",
+		Artist:  "Artist",
+		Comment: "Comment",
+		BPM:     120,
+		Key:     &key,
+		Loops:   []soundio.Loop{{Start: 100, End: 900, Mode: soundio.LoopPingPong}},
+		Markers: []soundio.Marker{{Position: 10, Label: "one"}},
+	}
+
+	// One beat at 120 BPM.
+	err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16, BufferSize: 64, Metadata: md}, tones(22050))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Input{Filename: filename}.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != md.Title || got.Artist != md.Artist || got.Comment != md.Comment {
+		t.Errorf("got tags %q, %q, %q", got.Title, got.Artist, got.Comment)
+	}
+	if math.Abs(got.BPM-md.BPM) > 1e-9 {
+		t.Errorf("got tempo %v, want %v", got.BPM, md.BPM)
+	}
+	if got.Key == nil || got.Key.Root.Letter() != music.BFlat || got.Key.Mode() != "minor" {
+		t.Errorf("got key %v, want Bb minor", got.Key)
+	}
+	if len(got.Loops) != 1 || got.Loops[0] != md.Loops[0] {
+		t.Errorf("got loops %v, want %v", got.Loops, md.Loops)
+	}
+	if len(got.Markers) != 1 || got.Markers[0] != md.Markers[0] {
+		t.Errorf("got markers %v, want %v", got.Markers, md.Markers)
+	}
+}
+
+func TestExactTempo(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	// About 3.3 beats and no beats at 90.5 BPM, neither of which the basc chunk can
+	// represent exactly.
+	for _, frames := range []int{95480, 0} {
+		md := soundio.Metadata{BPM: 90.5}
+		err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16, BufferSize: 64, Metadata: md}, tones(frames))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Input{Filename: filename}.Metadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.BPM != md.BPM {
+			t.Errorf("%d frames: got tempo %v, want %v", frames, got.BPM, md.BPM)
+		}
+	}
+}
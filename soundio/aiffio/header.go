@@ -0,0 +1,371 @@
+package aiffio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"math"
+	"os"
+)
+
+// Play modes of a Loop.
+const (
+	NoLooping = iota
+	ForwardLooping
+	ForwardBackwardLooping
+)
+
+// A Marker is a named position in a file.
+type Marker struct {
+	ID       int16
+	Position uint32 // sample frame
+	Name     string
+}
+
+// A Loop plays the frames between two markers repeatedly.
+type Loop struct {
+	PlayMode int16
+	Begin    int16 // marker ID
+	End      int16 // marker ID
+}
+
+// Instrument holds the parameters of an INST chunk, describing how a sampler
+// should play the sound.
+type Instrument struct {
+	BaseNote     int8 // MIDI note number
+	Detune       int8 // cents
+	LowNote      int8
+	HighNote     int8
+	LowVelocity  int8
+	HighVelocity int8
+	Gain         int16 // decibels
+	SustainLoop  Loop
+	ReleaseLoop  Loop
+}
+
+// Header describes the contents of an AIFF or AIFF-C file.
+type Header struct {
+	SampleRate float64
+	Channels   int
+	Format     soundio.SampleFormat
+
+	// Byte order of the samples; little-endian for AIFF-C 'sowt' files.
+	ByteOrder binary.ByteOrder
+
+	// Number of sample frames in the file.
+	Frames int64
+
+	// Four-character compression type; "NONE" for plain AIFF files.
+	Compression string
+
+	Markers    []Marker
+	Instrument *Instrument // nil if there is no INST chunk
+
+	// Metadata gathered from the NAME, AUTH and ANNO chunks, the markers and
+	// loops, the tempo and key of an Apple Loops basc chunk and the exact tempo
+	// of gosound's private APPL chunk.
+	Metadata soundio.Metadata
+
+	// Offset and size of the sample data.
+	dataOffset int64
+	dataSize   int64
+}
+
+// BadFileError is returned when a file is not an AIFF file that can be read.
+type BadFileError struct {
+	Reason string
+}
+
+func (err BadFileError) Error() string {
+	return fmt.Sprintf("aiffio: %s", err.Reason)
+}
+
+// ReadHeader reads the format and metadata of an AIFF or AIFF-C file without
+// reading its sample data.
+func ReadHeader(filename string) (h Header, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+
+	return readHeader(f)
+}
+
+// Parse every chunk of a FORM AIFF or AIFC file, skipping over the sample
+// data.
+func readHeader(r io.ReadSeeker) (h Header, err error) {
+	var form [12]byte
+	_, err = io.ReadFull(r, form[:])
+	if err != nil {
+		return h, err
+	}
+	if string(form[0:4]) != "FORM" {
+		return h, BadFileError{"not an IFF file"}
+	}
+
+	isAIFC := false
+	switch string(form[8:12]) {
+	case "AIFF":
+	case "AIFC":
+		isAIFC = true
+	default:
+		return h, BadFileError{"not an AIFF file"}
+	}
+
+	foundCommon := false
+	pos := int64(12)
+	texts := make(map[string][]string)
+	var basc []byte
+	var tempo float64
+
+	for {
+		var chunkHeader [8]byte
+		_, err = io.ReadFull(r, chunkHeader[:])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return h, err
+		}
+		pos += 8
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.BigEndian.Uint32(chunkHeader[4:8]))
+
+		if id == "SSND" {
+			var ssnd [8]byte
+			_, err = io.ReadFull(r, ssnd[:])
+			if err != nil {
+				return h, err
+			}
+			offset := int64(binary.BigEndian.Uint32(ssnd[0:4]))
+			h.dataOffset = pos + 8 + offset
+			h.dataSize = size - 8 - offset
+
+			pos, err = r.Seek(pos+size+size%2, io.SeekStart)
+			if err != nil {
+				return h, err
+			}
+			continue
+		}
+
+		var body []byte
+		body, err = readChunk(r, size+size%2)
+		if err != nil {
+			// Tolerate a truncated trailing chunk.
+			if h.dataOffset != 0 {
+				break
+			}
+			return h, err
+		}
+		pos += int64(len(body))
+		body = body[:size]
+
+		switch id {
+		case "COMM":
+			err = h.parseCommon(body, isAIFC)
+			if err != nil {
+				return h, err
+			}
+			foundCommon = true
+
+		case "MARK":
+			h.Markers = parseMarkers(body)
+
+		case "INST":
+			if len(body) >= 20 {
+				h.Instrument = parseInstrument(body)
+			}
+
+		case "NAME", "AUTH", "ANNO":
+			texts[id] = append(texts[id], string(body))
+
+		case "basc":
+			basc = body
+
+		case "APPL":
+			if bpm := parseTempo(body); bpm > 0 {
+				tempo = bpm
+			}
+		}
+	}
+
+	if !foundCommon {
+		return h, BadFileError{"no COMM chunk"}
+	}
+	if h.dataOffset == 0 {
+		if h.Frames != 0 {
+			return h, BadFileError{"no SSND chunk"}
+		}
+		h.dataOffset = pos
+	}
+
+	// Prefer the frame count from COMM, which excludes any padding.
+	if max := h.dataSize / int64(h.Channels*h.Format.Size()); h.Frames > max {
+		h.Frames = max
+	}
+	h.dataSize = h.Frames * int64(h.Channels*h.Format.Size())
+	h.gatherMetadata(texts, basc, tempo)
+
+	return h, nil
+}
+
+func (h *Header) parseCommon(body []byte, isAIFC bool) (err error) {
+	if len(body) < 18 {
+		return BadFileError{"short COMM chunk"}
+	}
+
+	h.Channels = int(binary.BigEndian.Uint16(body[0:2]))
+	h.Frames = int64(binary.BigEndian.Uint32(body[2:6]))
+	bits := int(binary.BigEndian.Uint16(body[6:8]))
+	h.SampleRate = readExtended(body[8:18])
+	h.Compression = "NONE"
+	h.ByteOrder = binary.BigEndian
+
+	if isAIFC {
+		if len(body) < 22 {
+			return BadFileError{"short AIFC COMM chunk"}
+		}
+		h.Compression = string(body[18:22])
+	}
+
+	if h.Channels == 0 {
+		return BadFileError{"no channels"}
+	}
+
+	// Samples narrower than their container are left-justified, so they can
+	// be read as the full container width.
+	container := (bits + 7) / 8 * 8
+
+	switch h.Compression {
+	case "NONE", "twos", "sowt":
+		if h.Compression == "sowt" {
+			h.ByteOrder = binary.LittleEndian
+		}
+		switch container {
+		case 8:
+			h.Format = soundio.Int8
+		case 16:
+			h.Format = soundio.Int16
+		case 24:
+			h.Format = soundio.Int24
+		case 32:
+			h.Format = soundio.Int32
+		default:
+			return BadFileError{fmt.Sprintf("unsupported sample size (%d bits)", bits)}
+		}
+	case "fl32", "FL32":
+		h.Format = soundio.Float32
+	case "fl64", "FL64":
+		h.Format = soundio.Float64
+	default:
+		return BadFileError{fmt.Sprintf("unsupported compression type %q", h.Compression)}
+	}
+
+	return nil
+}
+
+// Read a chunk body of 'size' bytes. The buffer grows as the data arrives,
+// so a corrupt size in a truncated file cannot cause a huge allocation.
+func readChunk(r io.Reader, size int64) (body []byte, err error) {
+	buf := new(bytes.Buffer)
+	_, err = io.CopyN(buf, r, size)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func parseMarkers(body []byte) (markers []Marker) {
+	if len(body) < 2 {
+		return nil
+	}
+
+	n := int(binary.BigEndian.Uint16(body[0:2]))
+	body = body[2:]
+	for i := 0; i < n && len(body) >= 7; i++ {
+		m := Marker{
+			ID:       int16(binary.BigEndian.Uint16(body[0:2])),
+			Position: binary.BigEndian.Uint32(body[2:6]),
+		}
+		// Pascal string, padded to an even total length.
+		length := int(body[6])
+		if 7+length > len(body) {
+			break
+		}
+		m.Name = string(body[7 : 7+length])
+		markers = append(markers, m)
+
+		size := 7 + length
+		if size+size%2 > len(body) {
+			break
+		}
+		body = body[size+size%2:]
+	}
+
+	return markers
+}
+
+func parseInstrument(body []byte) *Instrument {
+	loop := func(b []byte) Loop {
+		return Loop{
+			PlayMode: int16(binary.BigEndian.Uint16(b[0:2])),
+			Begin:    int16(binary.BigEndian.Uint16(b[2:4])),
+			End:      int16(binary.BigEndian.Uint16(b[4:6])),
+		}
+	}
+
+	return &Instrument{
+		BaseNote:     int8(body[0]),
+		Detune:       int8(body[1]),
+		LowNote:      int8(body[2]),
+		HighNote:     int8(body[3]),
+		LowVelocity:  int8(body[4]),
+		HighVelocity: int8(body[5]),
+		Gain:         int16(binary.BigEndian.Uint16(body[6:8])),
+		SustainLoop:  loop(body[8:14]),
+		ReleaseLoop:  loop(body[14:20]),
+	}
+}
+
+// Decode an 80-bit IEEE 754 extended precision number, as used for the
+// sample rate in COMM chunks.
+func readExtended(b []byte) float64 {
+	sign := 1.0
+	if b[0]&0x80 != 0 {
+		sign = -1
+	}
+	exp := int(binary.BigEndian.Uint16(b[0:2]) & 0x7FFF)
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+
+	if exp == 0 && mantissa == 0 {
+		return 0
+	}
+	if exp == 0x7FFF {
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(float64(mantissa), exp-16383-63)
+}
+
+// Encode 'x' as an 80-bit IEEE 754 extended precision number.
+func writeExtended(b []byte, x float64) {
+	for i := range b[:10] {
+		b[i] = 0
+	}
+	if x == 0 {
+		return
+	}
+
+	var sign uint16
+	if x < 0 {
+		sign = 0x8000
+		x = -x
+	}
+
+	frac, exp := math.Frexp(x) // x = frac * 2^exp, 0.5 <= frac < 1
+	binary.BigEndian.PutUint16(b[0:2], sign|uint16(exp-1+16383))
+	binary.BigEndian.PutUint64(b[2:10], uint64(math.Ldexp(frac, 64)))
+}
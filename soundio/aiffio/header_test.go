@@ -0,0 +1,55 @@
+package aiffio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/soundio"
+	"runtime"
+	"testing"
+)
+
+// A FORM AIFF file of 4 mono 16-bit frames followed by 'extra', which is
+// written as it is.
+func aiffFile(extra []byte) []byte {
+	body := new(bytes.Buffer)
+	body.WriteString("AIFF")
+	comm := commonChunk(soundio.Int16, 1, 44100, "")
+	binary.BigEndian.PutUint32(comm[2:6], 4)
+	writeChunk(body, "COMM", comm)
+	ssnd := make([]byte, 8+8)
+	writeChunk(body, "SSND", ssnd)
+	body.Write(extra)
+
+	buf := new(bytes.Buffer)
+	writeChunk(buf, "FORM", body.Bytes())
+	return buf.Bytes()
+}
+
+// The final marker's name is odd-sized and missing its pad byte.
+func TestParseMarkersMissingPad(t *testing.T) {
+	markers := []byte{0, 2, 0, 1, 0, 0, 0, 0, 1, 'a', 0, 2, 0, 0, 0, 3, 2, 'b', 'c'}
+	if got := parseMarkers(markers); len(got) != 2 || got[1].Name != "bc" {
+		t.Errorf("got markers %v", got)
+	}
+}
+
+// A truncated trailing chunk declaring a huge size must neither fail nor
+// allocate its declared size.
+func TestTruncatedChunk(t *testing.T) {
+	file := aiffFile([]byte("junk\xff\xff\xff\xf0some data"))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	h, err := readHeader(bytes.NewReader(file))
+	runtime.ReadMemStats(&after)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames != 4 {
+		t.Errorf("got %d frames, want 4", h.Frames)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes", n)
+	}
+}
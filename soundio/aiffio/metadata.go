@@ -0,0 +1,240 @@
+package aiffio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/soundio"
+	"math"
+	"strings"
+)
+
+// Scale types of the Apple Loops basc chunk.
+const (
+	bascMinor   = 1
+	bascMajor   = 2
+	bascNeither = 3
+)
+
+// Loop types of the basc chunk.
+const (
+	bascLoop    = 0
+	bascOneShot = 1
+)
+
+// Size of a basc chunk, including its reserved bytes.
+const bascSize = 84
+
+// Application signature of gosound's private APPL chunk, which holds the exact
+// tempo as a big-endian float64 after the signature. It is not a registered
+// format and other software ignores it.
+const tempoSignature = "gsnd"
+
+// Play modes of an INST loop, indexed by soundio.LoopMode. Backward loops
+// cannot be represented and are played forwards.
+var playModes = []int16{ForwardLooping, ForwardBackwardLooping, ForwardLooping}
+
+// Fill in h.Metadata from the text chunks, markers, instrument and the basc
+// chunk 'basc', which may be nil. A nonzero 'tempo' from gosound's private APPL
+// chunk is preferred over the tempo derived from the basc chunk.
+func (h *Header) gatherMetadata(texts map[string][]string, basc []byte, tempo float64) {
+	h.Metadata.Title = strings.Join(texts["NAME"], "\n")
+	h.Metadata.Artist = strings.Join(texts["AUTH"], "\n")
+	h.Metadata.Comment = strings.Join(texts["ANNO"], "\n")
+
+	positions := make(map[int16]int64)
+	for _, m := range h.Markers {
+		positions[m.ID] = int64(m.Position)
+	}
+
+	// Markers that bound a loop are reported as part of the loop.
+	loopMarkers := make(map[int16]bool)
+	if inst := h.Instrument; inst != nil {
+		for _, loop := range []Loop{inst.SustainLoop, inst.ReleaseLoop} {
+			begin, ok1 := positions[loop.Begin]
+			end, ok2 := positions[loop.End]
+			if loop.PlayMode == NoLooping || !ok1 || !ok2 || end <= begin {
+				continue
+			}
+
+			mode := soundio.LoopForward
+			if loop.PlayMode == ForwardBackwardLooping {
+				mode = soundio.LoopPingPong
+			}
+			h.Metadata.Loops = append(h.Metadata.Loops, soundio.Loop{
+				Start: begin,
+				End:   end,
+				Mode:  mode,
+			})
+			loopMarkers[loop.Begin] = true
+			loopMarkers[loop.End] = true
+		}
+	}
+
+	for _, m := range h.Markers {
+		if !loopMarkers[m.ID] {
+			h.Metadata.Markers = append(h.Metadata.Markers, soundio.Marker{
+				Position: int64(m.Position),
+				Label:    m.Name,
+			})
+		}
+	}
+
+	if len(basc) >= 18 {
+		beats := float64(binary.BigEndian.Uint32(basc[4:8]))
+		root := binary.BigEndian.Uint16(basc[8:10])
+		scaleType := binary.BigEndian.Uint16(basc[10:12])
+		denominator := float64(binary.BigEndian.Uint16(basc[14:16]))
+
+		if h.Frames > 0 && denominator > 0 {
+			seconds := float64(h.Frames) / h.SampleRate
+			h.Metadata.BPM = beats * 4 / denominator * 60 / seconds
+		}
+
+		if root != 0 || scaleType == bascMinor || scaleType == bascMajor {
+			key := &music.Scale{Root: music.MakeNote(music.NoteLetter(root%12), 4)}
+			switch scaleType {
+			case bascMinor:
+				key.Intervals = music.Minor
+			case bascMajor:
+				key.Intervals = music.Major
+			}
+			h.Metadata.Key = key
+		}
+	}
+
+	if tempo > 0 {
+		h.Metadata.BPM = tempo
+	}
+}
+
+// Tempo held by the APPL chunk 'body', or zero if it is not gosound's private
+// tempo chunk.
+func parseTempo(body []byte) float64 {
+	if len(body) < 12 || string(body[0:4]) != tempoSignature {
+		return 0
+	}
+	bpm := math.Float64frombits(binary.BigEndian.Uint64(body[4:12]))
+	if math.IsNaN(bpm) || math.IsInf(bpm, 0) || bpm <= 0 {
+		return 0
+	}
+	return bpm
+}
+
+// Add the markers and loops of 'md' to those given explicitly. The loops are
+// written as the sustain and release loops of a new instrument, unless 'inst'
+// is given.
+func mergeMetadata(markers []Marker, inst *Instrument, md soundio.Metadata) ([]Marker, *Instrument) {
+	var nextID int16 = 1
+	for _, m := range markers {
+		if m.ID >= nextID {
+			nextID = m.ID + 1
+		}
+	}
+
+	markers = append([]Marker(nil), markers...)
+	addMarker := func(position int64, name string) (id int16) {
+		id = nextID
+		markers = append(markers, Marker{
+			ID:       id,
+			Position: uint32(position),
+			Name:     name,
+		})
+		nextID++
+		return id
+	}
+
+	for _, m := range md.Markers {
+		addMarker(m.Position, m.Label)
+	}
+
+	if inst != nil || len(md.Loops) == 0 {
+		return markers, inst
+	}
+
+	inst = &Instrument{
+		BaseNote:     60,
+		LowNote:      0,
+		HighNote:     127,
+		LowVelocity:  1,
+		HighVelocity: 127,
+	}
+	for i, loop := range md.Loops {
+		if i >= 2 {
+			break
+		}
+
+		mode := playModes[0]
+		if int(loop.Mode) < len(playModes) {
+			mode = playModes[loop.Mode]
+		}
+		l := Loop{
+			PlayMode: mode,
+			Begin:    addMarker(loop.Start, "loop start"),
+			End:      addMarker(loop.End, "loop end"),
+		}
+		if i == 0 {
+			inst.SustainLoop = l
+		} else {
+			inst.ReleaseLoop = l
+		}
+	}
+
+	return markers, inst
+}
+
+// Write the NAME, AUTH and ANNO chunks.
+func writeTexts(buf *bytes.Buffer, md soundio.Metadata) {
+	for _, text := range []struct{ id, value string }{
+		{"NAME", md.Title},
+		{"AUTH", md.Artist},
+		{"ANNO", md.Comment},
+	} {
+		if text.value != "" {
+			writeChunk(buf, text.id, []byte(text.value))
+		}
+	}
+}
+
+// Write gosound's private APPL chunk holding the exact tempo of 'md', if it has
+// one.
+func writeTempo(buf *bytes.Buffer, md soundio.Metadata) {
+	if md.BPM <= 0 {
+		return
+	}
+	body := make([]byte, 12)
+	copy(body[0:4], tempoSignature)
+	binary.BigEndian.PutUint64(body[4:12], math.Float64bits(md.BPM))
+	writeChunk(buf, "APPL", body)
+}
+
+// Body of a basc chunk holding the tempo and key of 'md', for a file of the
+// given length. The tempo is stored as a whole number of beats.
+func bascChunk(md soundio.Metadata, sampleRate float64, frames int64, looped bool) []byte {
+	scaleType := uint16(bascNeither)
+	var root uint16
+	if md.Key != nil {
+		root = uint16(60 + md.Key.Root.Letter())
+		switch md.Key.Mode() {
+		case "major":
+			scaleType = bascMajor
+		case "minor", "harmonic minor", "melodic minor":
+			scaleType = bascMinor
+		}
+	}
+
+	loopType := uint16(bascOneShot)
+	if looped {
+		loopType = bascLoop
+	}
+
+	body := make([]byte, bascSize)
+	binary.BigEndian.PutUint32(body[0:4], 1) // version
+	binary.BigEndian.PutUint32(body[4:8], uint32(float64(frames)/sampleRate*md.BPM/60+0.5))
+	binary.BigEndian.PutUint16(body[8:10], root)
+	binary.BigEndian.PutUint16(body[10:12], scaleType)
+	binary.BigEndian.PutUint16(body[12:14], 4) // time signature
+	binary.BigEndian.PutUint16(body[14:16], 4)
+	binary.BigEndian.PutUint16(body[16:18], loopType)
+	return body
+}
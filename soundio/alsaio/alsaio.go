@@ -1,6 +1,8 @@
 package alsaio
 
 import (
+	"encoding/binary"
+	"fmt"
 	"github.com/kierdavis/gosound/soundio"
 	"github.com/tryphon/alsa-go"
 )
@@ -20,6 +22,9 @@ var DefaultInput = Input{
 }
 
 func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	if si.BufferSize <= 0 {
+		si.BufferSize = soundio.DefaultBufferSize
+	}
 	errChan = make(chan error, 2)
 	
 	channels = make([]chan float64, si.Channels)
@@ -55,6 +60,7 @@ func (si Input) Read() (sampleRate float64, channels []chan float64, errChan cha
 		}
 	
 		byteBuffer := make([]uint8, si.Channels*si.BufferSize*2)
+		buffer := make([]float64, si.Channels*si.BufferSize)
 		
 		for {
 			numBytes, err := handle.Read(byteBuffer)
@@ -64,11 +70,9 @@ func (si Input) Read() (sampleRate float64, channels []chan float64, errChan cha
 			}
 			
 			for i := 0; i < numBytes/2; i++ {
-				lo := uint16(byteBuffer[i*2])
-				hi := uint16(byteBuffer[i*2+1])
-				sample := float64(int16((hi << 8) | lo)) / 32767.0
-				channels[i % len(channels)] <- sample
+				buffer[i] = soundio.Int16.Decode(byteBuffer[i*2:], binary.LittleEndian)
 			}
+			soundio.Deinterlace(buffer[:numBytes/2], channels)
 		}
 	}()
 	
@@ -79,14 +83,34 @@ func (si Input) Read() (sampleRate float64, channels []chan float64, errChan cha
 type Output struct {
 	Device string
 	BufferSize int
+	Format soundio.SampleFormat
+	Dither soundio.Dither
+	LengthPolicy soundio.LengthPolicy
 }
 
 var DefaultOutput = Output{
 	Device: "default",
 	BufferSize: 4096,
+	Format: soundio.Int16,
+	Dither: soundio.TPDF,
+}
+
+// ALSA equivalents of the sample formats. All are little-endian.
+var alsaFormats = map[soundio.SampleFormat]alsa.SampleFormat{
+	soundio.Int8: alsa.SampleFormatS8,
+	soundio.Uint8: alsa.SampleFormatU8,
+	soundio.Int16: alsa.SampleFormatS16LE,
+	soundio.Int24: alsa.SampleFormatS24LE,
+	soundio.Int32: alsa.SampleFormatS32LE,
+	soundio.Float32: alsa.SampleFormatFloatLE,
 }
 
 func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	alsaFormat, ok := alsaFormats[so.Format]
+	if !ok {
+		return fmt.Errorf("alsaio: unsupported sample format: %s", so.Format)
+	}
+	
 	handle := alsa.New()
 	err = handle.Open(so.Device, alsa.StreamTypePlayback, alsa.ModeBlock)
 	if err != nil {
@@ -94,7 +118,7 @@ func (so Output) Write(sampleRate float64, channels []chan float64) (err error)
 	}
 	defer handle.Close()
 	
-	handle.SampleFormat = alsa.SampleFormatS16LE
+	handle.SampleFormat = alsaFormat
 	handle.SampleRate = int(sampleRate)
 	handle.Channels = len(channels)
 	err = handle.ApplyHwParams()
@@ -102,19 +126,31 @@ func (so Output) Write(sampleRate float64, channels []chan float64) (err error)
 		return err
 	}
 	
-	byteBuffer := make([]uint8, len(channels)*so.BufferSize*2)
+	// S24_LE samples occupy the low three bytes of four.
+	sampleSize := so.Format.Size()
+	if so.Format == soundio.Int24 {
+		sampleSize = 4
+	}
+	
+	q := soundio.NewQuantizer(so.Format, so.Dither, len(channels))
 	
-	for buffer := range soundio.Interlace(channels, so.BufferSize) {
-		for i, x := range buffer {
-			y := int16(x * 32767)
-			byteBuffer[i*2] = uint8(y)
-			byteBuffer[i*2+1] = uint8(y >> 8)
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	byteBuffer := make([]uint8, len(channels)*il.BufferSize*sampleSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		if so.Format == soundio.Int24 {
+			for i, x := range buffer {
+				v := q.Quantize(x, i % len(channels))
+				binary.LittleEndian.PutUint32(byteBuffer[i*4:], uint32(v))
+			}
+		} else {
+			q.Encode(byteBuffer, buffer, binary.LittleEndian)
 		}
 		
-		_, err = handle.Write(byteBuffer)
+		_, err = handle.Write(byteBuffer[:len(buffer)*sampleSize])
 		if err != nil {
 			return err
 		}
+		il.Release(buffer)
 	}
 	
 	err = handle.Drain()
@@ -122,5 +158,5 @@ func (so Output) Write(sampleRate float64, channels []chan float64) (err error)
 		return err
 	}
 	
-	return nil
+	return il.Err()
 }
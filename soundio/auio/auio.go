@@ -0,0 +1,431 @@
+// Package auio reads and writes Sun/NeXT .au files without cgo.
+//
+// Linear PCM (8 to 32 bit), float, double, mu-law and A-law encodings are
+// supported. Based on the description at
+// http://pubs.opengroup.org/external/auformat.html.
+package auio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+)
+
+// Encoding identifies the format of the samples in a file.
+type Encoding uint32
+
+const (
+	MuLaw    Encoding = 1
+	Linear8  Encoding = 2
+	Linear16 Encoding = 3
+	Linear24 Encoding = 4
+	Linear32 Encoding = 5
+	Float    Encoding = 6
+	Double   Encoding = 7
+	ALaw     Encoding = 27
+)
+
+// Data size recorded when the length of the data is not known.
+const unknownSize = 0xFFFFFFFF
+
+// Limits on the header fields of files that are read, so that a corrupt
+// header cannot cause a huge allocation.
+const (
+	maxChannels   = 1024
+	maxDataOffset = 1 << 24
+)
+
+// Size of a sample in bytes, or zero if the encoding is not supported.
+func (e Encoding) Size() int {
+	switch e {
+	case MuLaw, ALaw, Linear8:
+		return 1
+	case Linear16:
+		return 2
+	case Linear24:
+		return 3
+	case Linear32, Float:
+		return 4
+	case Double:
+		return 8
+	}
+	return 0
+}
+
+func (e Encoding) String() string {
+	switch e {
+	case MuLaw:
+		return "mu-law"
+	case ALaw:
+		return "A-law"
+	case Linear8, Linear16, Linear24, Linear32, Float, Double:
+		return e.format().String()
+	}
+	return fmt.Sprintf("encoding %d", uint32(e))
+}
+
+// Encoding of the linear format, for those that are not companded.
+func (e Encoding) format() soundio.SampleFormat {
+	switch e {
+	case Linear8:
+		return soundio.Int8
+	case Linear16:
+		return soundio.Int16
+	case Linear24:
+		return soundio.Int24
+	case Linear32:
+		return soundio.Int32
+	case Float:
+		return soundio.Float32
+	}
+	return soundio.Float64
+}
+
+func (e Encoding) decode(src []byte) float64 {
+	switch e {
+	case MuLaw:
+		return float64(decodeMuLaw(src[0])) / 32768
+	case ALaw:
+		return float64(decodeALaw(src[0])) / 32768
+	}
+	return e.format().Decode(src, binary.BigEndian)
+}
+
+// Encode an interleaved buffer. Companded encodings are converted from 16-bit
+// samples.
+func (e Encoding) encode(dst []byte, buffer []float64, q *soundio.Quantizer) {
+	switch e {
+	case MuLaw:
+		for i, x := range buffer {
+			dst[i] = encodeMuLaw(int16(q.Quantize(x, i%q.Channels)))
+		}
+	case ALaw:
+		for i, x := range buffer {
+			dst[i] = encodeALaw(int16(q.Quantize(x, i%q.Channels)))
+		}
+	default:
+		q.Encode(dst, buffer, binary.BigEndian)
+	}
+}
+
+// Header describes the contents of a .au file.
+type Header struct {
+	SampleRate float64
+	Channels   int
+	Encoding   Encoding
+	Annotation string
+
+	// Number of sample frames in the file, or -1 if unknown.
+	Frames int64
+
+	dataOffset int64
+	dataSize   int64 // -1 if unknown
+}
+
+// BadFileError is returned when a file is not a .au file that can be read.
+type BadFileError struct {
+	Reason string
+}
+
+func (err BadFileError) Error() string {
+	return fmt.Sprintf("auio: %s", err.Reason)
+}
+
+// ReadHeader reads the format and annotation of a .au file without reading its
+// sample data.
+func ReadHeader(filename string) (h Header, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+
+	return readHeader(f)
+}
+
+// Parse the header of a .au file, leaving 'r' at the start of the sample data.
+func readHeader(r io.Reader) (h Header, err error) {
+	var fields [24]byte
+	_, err = io.ReadFull(r, fields[:])
+	if err != nil {
+		return h, err
+	}
+	if string(fields[0:4]) != ".snd" {
+		return h, BadFileError{"not a .au file"}
+	}
+
+	h.dataOffset = int64(binary.BigEndian.Uint32(fields[4:8]))
+	h.dataSize = int64(binary.BigEndian.Uint32(fields[8:12]))
+	h.Encoding = Encoding(binary.BigEndian.Uint32(fields[12:16]))
+	h.SampleRate = float64(binary.BigEndian.Uint32(fields[16:20]))
+	h.Channels = int(binary.BigEndian.Uint32(fields[20:24]))
+
+	if h.dataOffset < 24 || h.dataOffset > maxDataOffset {
+		return h, BadFileError{"invalid data offset"}
+	}
+	if h.Encoding.Size() == 0 {
+		return h, BadFileError{fmt.Sprintf("unsupported encoding %d", h.Encoding)}
+	}
+	if h.Channels == 0 {
+		return h, BadFileError{"no channels"}
+	}
+	if h.Channels > maxChannels {
+		return h, BadFileError{fmt.Sprintf("too many channels (%d)", h.Channels)}
+	}
+
+	// The buffer grows as the data arrives, so a truncated file cannot cause
+	// a large allocation.
+	buf := new(bytes.Buffer)
+	_, err = io.CopyN(buf, r, h.dataOffset-24)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return h, err
+	}
+	annotation := buf.Bytes()
+	if i := bytes.IndexByte(annotation, 0); i >= 0 {
+		annotation = annotation[:i]
+	}
+	h.Annotation = string(annotation)
+
+	if h.dataSize == unknownSize {
+		h.dataSize = -1
+		h.Frames = -1
+	} else {
+		h.Frames = h.dataSize / int64(h.Channels*h.Encoding.Size())
+	}
+
+	return h, nil
+}
+
+type Input struct {
+	Filename   string
+	BufferSize int
+
+	// Part of the file to read. The zero Region reads the whole file once.
+	Region soundio.Region
+}
+
+// Info reads the format and length of the file without streaming it.
+func (si Input) Info() (info soundio.FileInfo, err error) {
+	h, err := ReadHeader(si.Filename)
+	if err != nil {
+		return info, err
+	}
+
+	return soundio.FileInfo{
+		SampleRate: h.SampleRate,
+		Channels:   h.Channels,
+		Frames:     h.Frames,
+		Format:     "AU " + h.Encoding.String(),
+	}, nil
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	h, err := readHeader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	channels = make([]chan float64, h.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			err2 := f.Close()
+			if err2 != nil {
+				errChan <- err2
+			}
+
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		frameSize := int64(h.Channels * h.Encoding.Size())
+		start, end := si.Region.Bounds(h.Frames)
+		if start == end {
+			return
+		}
+
+		region := h
+		region.dataSize = -1
+		if end >= 0 {
+			region.dataSize = (end - start) * frameSize
+		}
+
+		err := si.Region.Repeat(func() (frames int64, err error) {
+			_, err = f.Seek(h.dataOffset+start*frameSize, io.SeekStart)
+			if err != nil {
+				return 0, err
+			}
+			return readSamples(bufio.NewReader(f), region, channels, si.BufferSize)
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return h.SampleRate, channels, errChan
+}
+
+// Read the sample data described by 'h' from 'r' and distribute it to
+// 'channels', returning the number of frames read. A negative data size means
+// read until EOF.
+func readSamples(r io.Reader, h Header, channels []chan float64, bufferSize int) (frames int64, err error) {
+	if bufferSize <= 0 {
+		bufferSize = soundio.DefaultBufferSize
+	}
+
+	sampleSize := h.Encoding.Size()
+	frameSize := sampleSize * h.Channels
+	byteBuffer := make([]byte, frameSize*bufferSize)
+	buffer := make([]float64, h.Channels*bufferSize)
+	remaining := h.dataSize
+
+	for remaining != 0 {
+		chunk := byteBuffer
+		if remaining > 0 && int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+			remaining = 0
+		} else if err != nil {
+			return frames, err
+		}
+
+		if remaining > 0 {
+			remaining -= int64(n)
+		}
+
+		n -= n % frameSize
+		for i := 0; i < n/sampleSize; i++ {
+			buffer[i] = h.Encoding.decode(byteBuffer[i*sampleSize:])
+		}
+		soundio.Deinterlace(buffer[:n/sampleSize], channels)
+		frames += int64(n / frameSize)
+	}
+
+	return frames, nil
+}
+
+type Output struct {
+	Filename   string
+	Encoding   Encoding // Linear16 if zero
+	BufferSize int
+
+	// Dither applied when converting to an integer or companded encoding.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	// Text stored in the header.
+	Annotation string
+}
+
+func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	if so.Encoding == 0 {
+		so.Encoding = Linear16
+	}
+
+	sampleSize := so.Encoding.Size()
+	if sampleSize == 0 {
+		return fmt.Errorf("auio: unsupported encoding %d", so.Encoding)
+	}
+
+	f, err := os.Create(so.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The annotation is NUL-terminated and padded to a multiple of 8 bytes.
+	annotation := append([]byte(so.Annotation), 0)
+	for len(annotation)%8 != 0 || len(annotation) < 8 {
+		annotation = append(annotation, 0)
+	}
+
+	header := make([]byte, 24, 24+len(annotation))
+	copy(header[0:4], ".snd")
+	binary.BigEndian.PutUint32(header[4:8], uint32(24+len(annotation)))
+	binary.BigEndian.PutUint32(header[8:12], unknownSize)
+	binary.BigEndian.PutUint32(header[12:16], uint32(so.Encoding))
+	binary.BigEndian.PutUint32(header[16:20], uint32(sampleRate))
+	binary.BigEndian.PutUint32(header[20:24], uint32(len(channels)))
+	header = append(header, annotation...)
+
+	w := bufio.NewWriter(f)
+	_, err = w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	dataSize := int64(0)
+
+	format := so.Encoding.format()
+	if so.Encoding == MuLaw || so.Encoding == ALaw {
+		format = soundio.Int16
+	}
+	q := soundio.NewQuantizer(format, so.Dither, len(channels))
+
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	byteBuffer := make([]byte, len(channels)*il.BufferSize*sampleSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		so.Encoding.encode(byteBuffer, buffer, q)
+
+		_, err = w.Write(byteBuffer[:len(buffer)*sampleSize])
+		if err != nil {
+			return err
+		}
+		dataSize += int64(len(buffer) * sampleSize)
+		il.Release(buffer)
+	}
+
+	err = il.Err()
+	if err != nil {
+		return err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	// Leave the size as unknown if it does not fit.
+	if dataSize >= unknownSize {
+		return nil
+	}
+
+	_, err = f.Seek(8, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(dataSize))
+	_, err = f.Write(size[:])
+	return err
+}
@@ -0,0 +1,117 @@
+package auio
+
+import (
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Two channels of tones at different frequencies.
+func tones(frames int) memio.Input {
+	channels := [][]float64{make([]float64, frames), make([]float64, frames)}
+	for i := 0; i < frames; i++ {
+		channels[0][i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		channels[1][i] = 0.25 * math.Sin(2*math.Pi*1000*float64(i)/44100)
+	}
+	return memio.Input{SampleRate: 44100, Channels: channels, BufferSize: 64}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.au")
+
+	// Largest error of each encoding on the test signal. The steps of the
+	// companded encodings are up to 1/32 at the levels used.
+	encodings := map[Encoding]float64{
+		Linear8:  1.0 / 128,
+		Linear16: 1.0 / 32768,
+		Linear24: 1.0 / 8388608,
+		Linear32: 1e-9,
+		Float:    1e-7,
+		Double:   1e-15,
+		MuLaw:    1.0 / 32,
+		ALaw:     1.0 / 32,
+	}
+
+	for encoding, tolerance := range encodings {
+		in := tones(1001)
+		so := Output{Filename: filename, Encoding: encoding, BufferSize: 64, Annotation: "note"}
+		if err := soundio.Copy(so, in); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := ReadHeader(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Encoding != encoding || h.Annotation != "note" || h.Frames != 1001 {
+			t.Errorf("encoding %d: got header %+v", encoding, h)
+		}
+
+		out := new(memio.Output)
+		if err := soundio.Copy(out, Input{Filename: filename, BufferSize: 64}); err != nil {
+			t.Fatal(err)
+		}
+
+		if out.SampleRate != in.SampleRate || len(out.Channels) != 2 {
+			t.Fatalf("encoding %d: got %d channels at %v Hz", encoding, len(out.Channels), out.SampleRate)
+		}
+		for c, buffer := range in.Channels {
+			if len(out.Channels[c]) != len(buffer) {
+				t.Fatalf("encoding %d: channel %d has %d samples, want %d", encoding, c, len(out.Channels[c]), len(buffer))
+			}
+			for i, x := range buffer {
+				if math.Abs(out.Channels[c][i]-x) > tolerance {
+					t.Errorf("encoding %d: channel %d sample %d is %v, want %v", encoding, c, i, out.Channels[c][i], x)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Reading with a zero buffer size must still deliver every frame.
+func TestZeroBufferSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.au")
+
+	if err := soundio.Copy(Output{Filename: filename, Encoding: Linear16, BufferSize: 64}, tones(1001)); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(memio.Output)
+	done := make(chan error)
+	go func() {
+		done <- soundio.Copy(out, Input{Filename: filename})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reading with a zero buffer size did not finish")
+	}
+	if len(out.Channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(out.Channels))
+	}
+	for c, buffer := range out.Channels {
+		if len(buffer) != 1001 {
+			t.Errorf("channel %d has %d samples, want 1001", c, len(buffer))
+		}
+	}
+}
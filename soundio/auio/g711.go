@@ -0,0 +1,87 @@
+package auio
+
+// G.711 companding, after the reference implementation by Sun Microsystems.
+
+const (
+	muLawBias = 0x84
+	muLawClip = 8159 // 14-bit
+)
+
+func decodeMuLaw(b byte) int16 {
+	b = ^b
+	t := (int(b&0x0F) << 3) + muLawBias
+	t <<= uint(b&0x70) >> 4
+	if b&0x80 != 0 {
+		return int16(muLawBias - t)
+	}
+	return int16(t - muLawBias)
+}
+
+func encodeMuLaw(v int16) byte {
+	x := int(v) >> 2
+	mask := byte(0xFF)
+	if x < 0 {
+		x = -x
+		mask = 0x7F
+	}
+	if x > muLawClip {
+		x = muLawClip
+	}
+	x += muLawBias >> 2
+
+	segment := segmentOf(x >> 6)
+	if segment >= 8 {
+		return 0x7F ^ mask
+	}
+	b := byte(segment<<4) | byte(x>>uint(segment+1)&0x0F)
+	return b ^ mask
+}
+
+func decodeALaw(b byte) int16 {
+	b ^= 0x55
+	t := int(b&0x0F) << 4
+	segment := uint(b&0x70) >> 4
+	switch segment {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= segment - 1
+	}
+	if b&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
+
+func encodeALaw(v int16) byte {
+	x := int(v) >> 3
+	mask := byte(0xD5)
+	if x < 0 {
+		x = -x - 1
+		mask = 0x55
+	}
+
+	segment := segmentOf(x >> 5)
+	var b byte
+	if segment >= 8 {
+		b = 0x7F
+	} else if segment < 2 {
+		b = byte(segment<<4) | byte(x>>1&0x0F)
+	} else {
+		b = byte(segment<<4) | byte(x>>uint(segment)&0x0F)
+	}
+	return b ^ mask
+}
+
+// Index of the highest set bit of 'x', plus one; at most 8.
+func segmentOf(x int) int {
+	segment := 0
+	for x > 0 && segment < 8 {
+		x >>= 1
+		segment++
+	}
+	return segment
+}
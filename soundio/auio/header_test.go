@@ -0,0 +1,54 @@
+package auio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// A .au header with the given data offset and channel count, followed by
+// 'extra'.
+func auFile(dataOffset, channels uint32, extra []byte) []byte {
+	header := make([]byte, 24)
+	copy(header[0:4], ".snd")
+	binary.BigEndian.PutUint32(header[4:8], dataOffset)
+	binary.BigEndian.PutUint32(header[8:12], unknownSize)
+	binary.BigEndian.PutUint32(header[12:16], uint32(Linear16))
+	binary.BigEndian.PutUint32(header[16:20], 44100)
+	binary.BigEndian.PutUint32(header[20:24], channels)
+	return append(header, extra...)
+}
+
+func TestBadHeader(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		file []byte
+	}{
+		{"huge data offset", auFile(0xFFFFFFF0, 1, make([]byte, 8))},
+		{"huge channel count", auFile(24, 0xFFFFFFFF, make([]byte, 8))},
+	} {
+		_, err := readHeader(bytes.NewReader(test.file))
+		if _, ok := err.(BadFileError); !ok {
+			t.Errorf("%s: got error %v, want a BadFileError", test.name, err)
+		}
+	}
+}
+
+// A truncated annotation must not allocate the size the header declares.
+func TestTruncatedAnnotation(t *testing.T) {
+	file := auFile(maxDataOffset, 1, []byte("note"))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := readHeader(bytes.NewReader(file))
+	runtime.ReadMemStats(&after)
+
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, want io.ErrUnexpectedEOF", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes", n)
+	}
+}
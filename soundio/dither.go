@@ -0,0 +1,125 @@
+package soundio
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+)
+
+// Dither selects the noise added to samples before they are rounded to an
+// integer format.
+type Dither int
+
+const (
+	// Round to the nearest value. The rounding error is correlated with the
+	// signal and heard as distortion on quiet material.
+	NoDither Dither = iota
+
+	// Add triangular (TPDF) noise of +/-1 LSB, making the error
+	// independent of the signal at the cost of a slightly higher noise floor.
+	TPDF
+
+	// TPDF dither with error-feedback noise shaping, which moves the noise
+	// towards frequencies the ear is less sensitive to. The filter is designed
+	// for 44.1 and 48 kHz.
+	ShapedTPDF
+)
+
+// Error feedback filter for ShapedTPDF: the 5-tap E-weighted filter of
+// Lipshitz, Vanderkooy and Wannamaker, "Minimally Audible Noise Shaping"
+// (JAES, 1991).
+var noiseShapingFilter = [...]float64{2.033, -2.165, 1.959, -1.590, 0.6149}
+
+// A Quantizer converts samples to a sample format, applying dither first if
+// the format is an integer one. It keeps state for each channel, so a
+// Quantizer should be used for a single stream. Its noise is generated from a
+// fixed seed, so that renders are reproducible.
+type Quantizer struct {
+	Format   SampleFormat
+	Dither   Dither
+	Channels int
+
+	// If non-zero, the number of significant bits of an integer format, for
+	// samples narrower than their container (such as 20-bit samples stored
+	// as Int24). Quantize returns values of this width, which Encode
+	// left-justifies in the container.
+	Bits int
+
+	rand   *rand.Rand
+	errors [][len(noiseShapingFilter)]float64 // most recent first
+}
+
+// NewQuantizer creates a Quantizer for a stream of 'numChannels' interleaved
+// channels.
+func NewQuantizer(format SampleFormat, dither Dither, numChannels int) (q *Quantizer) {
+	return &Quantizer{
+		Format:   format,
+		Dither:   dither,
+		Channels: numChannels,
+		rand:     rand.New(rand.NewSource(1)),
+		errors:   make([][len(noiseShapingFilter)]float64, numChannels),
+	}
+}
+
+// Quantize returns the integer sample for 'x', which belongs to the given
+// channel, with dither applied and values outside [-1, 1) clipped. The format
+// must be an integer format.
+func (q *Quantizer) Quantize(x float64, channel int) (v int32) {
+	scale := q.Format.scale()
+	if q.Bits > 0 {
+		scale = float64(uint64(1) << uint(q.Bits-1))
+	}
+	y := x * scale
+
+	switch q.Dither {
+	case NoDither:
+		return round(y, scale)
+
+	case TPDF:
+		return round(y+q.tpdf(), scale)
+
+	case ShapedTPDF:
+		e := &q.errors[channel]
+		shaped := y
+		for i, c := range noiseShapingFilter {
+			shaped -= c * e[i]
+		}
+
+		v = round(shaped+q.tpdf(), scale)
+
+		// Limit the error fed back after clipping, which would otherwise make
+		// the filter unstable.
+		err := math.Max(-2, math.Min(2, float64(v)-shaped))
+		copy(e[1:], e[:len(e)-1])
+		e[0] = err
+		return v
+	}
+
+	return round(y, scale)
+}
+
+// Triangular noise in [-1, 1) LSB.
+func (q *Quantizer) tpdf() float64 {
+	return q.rand.Float64() - q.rand.Float64()
+}
+
+// Encode writes an interleaved buffer of samples to 'dst' in the quantizer's
+// format. Float formats are written without dither.
+func (q *Quantizer) Encode(dst []byte, buffer []float64, order binary.ByteOrder) {
+	size := q.Format.Size()
+
+	if q.Format.IsFloat() {
+		for i, x := range buffer {
+			q.Format.Encode(dst[i*size:], x, order)
+		}
+		return
+	}
+
+	shift := uint(0)
+	if q.Bits > 0 {
+		shift = uint(q.Format.Bits() - q.Bits)
+	}
+	for i, x := range buffer {
+		q.Format.putInt(dst[i*size:], q.Quantize(x, i%q.Channels)<<shift, order)
+	}
+}
@@ -0,0 +1,169 @@
+package flacio
+
+import (
+	"bufio"
+	"math/bits"
+)
+
+var (
+	crc8Table  [256]uint8
+	crc16Table [256]uint16
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		c8 := uint8(i)
+		c16 := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		crc8Table[i] = c8
+		crc16Table[i] = c16
+	}
+}
+
+func crc8(data []byte) (crc uint8) {
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
+
+func crc16(data []byte) (crc uint16) {
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[uint8(crc>>8)^b]
+	}
+	return crc
+}
+
+// A bitReader reads big-endian bit fields, keeping running CRCs of the bytes
+// consumed so that frame headers and frames can be checked.
+type bitReader struct {
+	r     *bufio.Reader
+	x     uint64
+	n     uint
+	crc8  uint8
+	crc16 uint16
+}
+
+func (br *bitReader) reset() {
+	br.x, br.n = 0, 0
+	br.crc8, br.crc16 = 0, 0
+}
+
+func (br *bitReader) fill() (err error) {
+	b, err := br.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	br.crc8 = crc8Table[br.crc8^b]
+	br.crc16 = br.crc16<<8 ^ crc16Table[uint8(br.crc16>>8)^b]
+	br.x = br.x<<8 | uint64(b)
+	br.n += 8
+	return nil
+}
+
+// Read an unsigned field of up to 56 bits.
+func (br *bitReader) read(n uint) (v uint64, err error) {
+	for br.n < n {
+		err = br.fill()
+		if err != nil {
+			return 0, err
+		}
+	}
+	br.n -= n
+	v = br.x >> br.n & (1<<n - 1)
+	br.x &= 1<<br.n - 1
+	return v, nil
+}
+
+// Read a two's complement field of up to 56 bits.
+func (br *bitReader) readSigned(n uint) (v int64, err error) {
+	u, err := br.read(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return int64(u<<(64-n)) >> (64 - n), nil
+}
+
+// Read a unary-coded value: the number of zero bits before the next one bit.
+func (br *bitReader) readUnary() (v uint64, err error) {
+	for {
+		if br.n == 0 {
+			err = br.fill()
+			if err != nil {
+				return 0, err
+			}
+		}
+		if br.x == 0 {
+			v += uint64(br.n)
+			br.n = 0
+			continue
+		}
+		zeros := uint(bits.LeadingZeros64(br.x)) - (64 - br.n)
+		v += uint64(zeros)
+		br.n -= zeros + 1
+		br.x &= 1<<br.n - 1
+		return v, nil
+	}
+}
+
+// Discard bits up to the next byte boundary.
+func (br *bitReader) align() {
+	br.n -= br.n % 8
+	br.x &= 1<<br.n - 1
+}
+
+// A bitWriter accumulates big-endian bit fields in memory.
+type bitWriter struct {
+	buf []byte
+	x   uint64
+	n   uint
+}
+
+// Write the low 'n' bits of 'v', where n is at most 56.
+func (bw *bitWriter) write(v uint64, n uint) {
+	bw.x = bw.x<<n | v&(1<<n-1)
+	bw.n += n
+	for bw.n >= 8 {
+		bw.n -= 8
+		bw.buf = append(bw.buf, byte(bw.x>>bw.n))
+	}
+}
+
+func (bw *bitWriter) writeSigned(v int64, n uint) {
+	bw.write(uint64(v), n)
+}
+
+func (bw *bitWriter) writeUnary(v uint64) {
+	for v >= 32 {
+		bw.write(0, 32)
+		v -= 32
+	}
+	bw.write(1, uint(v)+1)
+}
+
+// Pad with zero bits up to the next byte boundary.
+func (bw *bitWriter) align() {
+	if bw.n%8 != 0 {
+		bw.write(0, 8-bw.n%8)
+	}
+}
+
+// Number of bits written so far.
+func (bw *bitWriter) len() int {
+	return len(bw.buf)*8 + int(bw.n)
+}
+
+func (bw *bitWriter) bytes() []byte {
+	return bw.buf
+}
@@ -0,0 +1,578 @@
+package flacio
+
+import (
+	"bufio"
+	"crypto/md5"
+	"hash"
+	"io"
+)
+
+// A Decoder reads the frames of a FLAC stream. Based on the format
+// specification at https://xiph.org/flac/format.html.
+type Decoder struct {
+	Info      StreamInfo
+	SeekTable []SeekPoint
+	Vendor    string
+
+	// Vorbis comments, keyed by upper-case field name.
+	Comments map[string]string
+
+	r           io.Reader
+	br          bitReader
+	audioOffset int64
+
+	md5    hash.Hash
+	verify bool // whether the signature can still be checked at the end
+	skip   int  // samples to drop from the next frame, after a seek
+
+	// Buffers reused between frames.
+	samples  [][]int32
+	residual []int64
+	md5Buf   []byte
+}
+
+// NewDecoder reads the metadata of a FLAC stream from 'r', leaving it ready to
+// decode the first frame. SeekSample is only available if 'r' is also an
+// io.Seeker.
+func NewDecoder(r io.Reader) (d *Decoder, err error) {
+	d = &Decoder{r: r}
+
+	d.audioOffset, err = d.readMetadata(r)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = BadFileError{"truncated metadata"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.br.r = bufio.NewReader(r)
+	d.md5 = md5.New()
+	d.verify = d.Info.MD5 != [16]byte{}
+	return d, nil
+}
+
+// SeekSample positions the decoder so that the next frame read starts at the
+// given sample. The nearest preceding seek point is used if there is one;
+// otherwise decoding restarts from the first frame. The MD5 signature is not
+// checked after a seek.
+func (d *Decoder) SeekSample(sample int64) (err error) {
+	seeker, ok := d.r.(io.Seeker)
+	if !ok {
+		return BadFileError{"stream is not seekable"}
+	}
+
+	var point SeekPoint
+	for _, p := range d.SeekTable {
+		if p.Sample <= sample && p.Sample >= point.Sample {
+			point = p
+		}
+	}
+
+	_, err = seeker.Seek(d.audioOffset+point.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	d.br.r.Reset(d.r)
+	d.br.reset()
+	d.verify = false
+	d.skip = int(sample - point.Sample)
+	return nil
+}
+
+// Number of samples per channel in the stream, or -1 if the STREAMINFO block
+// does not record it.
+func (d *Decoder) frames() int64 {
+	if d.Info.TotalSamples == 0 {
+		return -1
+	}
+	return d.Info.TotalSamples
+}
+
+// ReadFrame decodes the next frame, returning its samples for each channel.
+// The returned slices are reused by the next call. At the end of the stream
+// the MD5 signature is checked, and io.EOF is returned if it matches.
+func (d *Decoder) ReadFrame() (samples [][]int32, err error) {
+	for {
+		samples, err = d.readFrame()
+		if err == io.EOF {
+			return nil, d.finish()
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, BadFileError{"truncated frame"}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if d.skip > 0 {
+			n := d.skip
+			if n > len(samples[0]) {
+				n = len(samples[0])
+			}
+			for i := range samples {
+				samples[i] = samples[i][n:]
+			}
+			d.skip -= n
+			if len(samples[0]) == 0 {
+				continue
+			}
+		}
+
+		if d.verify {
+			d.updateMD5(samples)
+		}
+		return samples, nil
+	}
+}
+
+func (d *Decoder) finish() (err error) {
+	if d.verify {
+		var sum [16]byte
+		copy(sum[:], d.md5.Sum(nil))
+		if sum != d.Info.MD5 {
+			return BadFileError{"MD5 signature mismatch"}
+		}
+	}
+	return io.EOF
+}
+
+// Feed the samples to the MD5 hash as interleaved little-endian integers.
+func (d *Decoder) updateMD5(samples [][]int32) {
+	bytesPerSample := (d.Info.BitsPerSample + 7) / 8
+	n := len(samples[0]) * len(samples) * bytesPerSample
+	if cap(d.md5Buf) < n {
+		d.md5Buf = make([]byte, n)
+	}
+	buf := d.md5Buf[:n]
+
+	i := 0
+	for j := range samples[0] {
+		for _, channel := range samples {
+			v := channel[j]
+			for k := 0; k < bytesPerSample; k++ {
+				buf[i] = byte(v >> uint(8*k))
+				i++
+			}
+		}
+	}
+	d.md5.Write(buf)
+}
+
+type frameHeader struct {
+	blockSize     int
+	channels      int
+	assignment    int
+	bitsPerSample int
+}
+
+// Channel assignments beyond independent channels.
+const (
+	leftSide  = 8
+	sideRight = 9
+	midSide   = 10
+)
+
+func (d *Decoder) readFrame() (samples [][]int32, err error) {
+	br := &d.br
+	br.reset()
+
+	sync, err := br.read(14)
+	if err == io.EOF && br.n == 0 {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if sync != 0x3FFE {
+		return nil, BadFileError{"lost frame sync"}
+	}
+
+	h, err := d.readFrameHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.samples) != h.channels {
+		d.samples = make([][]int32, h.channels)
+	}
+	for i := range d.samples {
+		if cap(d.samples[i]) < h.blockSize {
+			d.samples[i] = make([]int32, h.blockSize)
+		}
+		d.samples[i] = d.samples[i][:h.blockSize]
+	}
+
+	for i, channel := range d.samples {
+		bps := h.bitsPerSample
+		if (h.assignment == leftSide || h.assignment == midSide) && i == 1 ||
+			h.assignment == sideRight && i == 0 {
+			bps++ // side channel
+		}
+		err = d.readSubframe(channel, bps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	br.align()
+	crc := br.crc16
+	footer, err := br.read(16)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if uint16(footer) != crc {
+		return nil, BadFileError{"frame CRC mismatch"}
+	}
+
+	decorrelate(d.samples, h.assignment)
+	return d.samples, nil
+}
+
+func (d *Decoder) readFrameHeader() (h frameHeader, err error) {
+	br := &d.br
+
+	fields, err := br.read(18)
+	if err != nil {
+		return h, io.ErrUnexpectedEOF
+	}
+	blockSizeCode := fields >> 12 & 0xF
+	sampleRateCode := fields >> 8 & 0xF
+	h.assignment = int(fields >> 4 & 0xF)
+	sampleSizeCode := fields >> 1 & 0x7
+
+	// Frame or sample number, in extended UTF-8 coding; only its length
+	// matters here.
+	first, err := br.read(8)
+	if err != nil {
+		return h, io.ErrUnexpectedEOF
+	}
+	for mask := uint64(0x80); first&mask != 0 && mask > 1; mask >>= 1 {
+		if mask != 0x80 {
+			_, err = br.read(8)
+			if err != nil {
+				return h, io.ErrUnexpectedEOF
+			}
+		}
+	}
+
+	switch {
+	case blockSizeCode == 0:
+		return h, BadFileError{"reserved block size"}
+	case blockSizeCode == 1:
+		h.blockSize = 192
+	case blockSizeCode <= 5:
+		h.blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		v, err := br.read(8)
+		if err != nil {
+			return h, io.ErrUnexpectedEOF
+		}
+		h.blockSize = int(v) + 1
+	case blockSizeCode == 7:
+		v, err := br.read(16)
+		if err != nil {
+			return h, io.ErrUnexpectedEOF
+		}
+		h.blockSize = int(v) + 1
+	default:
+		h.blockSize = 256 << (blockSizeCode - 8)
+	}
+
+	// The sample rate is taken from STREAMINFO; skip any explicit value.
+	switch sampleRateCode {
+	case 12:
+		_, err = br.read(8)
+	case 13, 14:
+		_, err = br.read(16)
+	case 15:
+		return h, BadFileError{"invalid sample rate"}
+	}
+	if err != nil {
+		return h, io.ErrUnexpectedEOF
+	}
+
+	switch sampleSizeCode {
+	case 0:
+		h.bitsPerSample = d.Info.BitsPerSample
+	case 1:
+		h.bitsPerSample = 8
+	case 2:
+		h.bitsPerSample = 12
+	case 4:
+		h.bitsPerSample = 16
+	case 5:
+		h.bitsPerSample = 20
+	case 6:
+		h.bitsPerSample = 24
+	case 7:
+		h.bitsPerSample = 32
+	default:
+		return h, BadFileError{"reserved sample size"}
+	}
+
+	switch {
+	case h.assignment < 8:
+		h.channels = h.assignment + 1
+	case h.assignment <= midSide:
+		h.channels = 2
+	default:
+		return h, BadFileError{"reserved channel assignment"}
+	}
+	if h.channels != d.Info.Channels {
+		return h, BadFileError{"channel count differs from STREAMINFO"}
+	}
+
+	crc := br.crc8
+	v, err := br.read(8)
+	if err != nil {
+		return h, io.ErrUnexpectedEOF
+	}
+	if uint8(v) != crc {
+		return h, BadFileError{"frame header CRC mismatch"}
+	}
+
+	return h, nil
+}
+
+func (d *Decoder) readSubframe(samples []int32, bps int) (err error) {
+	br := &d.br
+
+	header, err := br.read(8)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if header&0x80 != 0 {
+		return BadFileError{"invalid subframe header"}
+	}
+	kind := int(header >> 1 & 0x3F)
+
+	wasted := 0
+	if header&1 != 0 {
+		k, err := br.readUnary()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		wasted = int(k) + 1
+		bps -= wasted
+	}
+
+	switch {
+	case kind == 0:
+		v, err := br.readSigned(uint(bps))
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		for i := range samples {
+			samples[i] = int32(v)
+		}
+
+	case kind == 1:
+		for i := range samples {
+			v, err := br.readSigned(uint(bps))
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			samples[i] = int32(v)
+		}
+
+	case kind >= 8 && kind <= 12:
+		order := kind - 8
+		err = d.readWarmup(samples, order, bps)
+		if err != nil {
+			return err
+		}
+		err = d.readResidual(samples, order)
+		if err != nil {
+			return err
+		}
+		restoreFixed(samples, order, d.residual)
+
+	case kind >= 32:
+		order := kind - 31
+		err = d.readWarmup(samples, order, bps)
+		if err != nil {
+			return err
+		}
+
+		v, err := br.read(4)
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if v == 0xF {
+			return BadFileError{"invalid LPC precision"}
+		}
+		precision := uint(v) + 1
+		shift, err := br.readSigned(5)
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if shift < 0 {
+			return BadFileError{"negative LPC shift"}
+		}
+
+		coeffs := make([]int64, order)
+		for i := range coeffs {
+			coeffs[i], err = br.readSigned(precision)
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+		}
+
+		err = d.readResidual(samples, order)
+		if err != nil {
+			return err
+		}
+		restoreLPC(samples, coeffs, uint(shift), d.residual)
+
+	default:
+		return BadFileError{"reserved subframe type"}
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= uint(wasted)
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) readWarmup(samples []int32, order int, bps int) (err error) {
+	if order > len(samples) {
+		return BadFileError{"predictor order exceeds block size"}
+	}
+	for i := 0; i < order; i++ {
+		v, err := d.br.readSigned(uint(bps))
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		samples[i] = int32(v)
+	}
+	return nil
+}
+
+// Read the Rice-coded residual of a subframe into d.residual.
+func (d *Decoder) readResidual(samples []int32, order int) (err error) {
+	br := &d.br
+	blockSize := len(samples)
+
+	if cap(d.residual) < blockSize {
+		d.residual = make([]int64, blockSize)
+	}
+	d.residual = d.residual[:blockSize]
+	residual := d.residual[order:]
+
+	method, err := br.read(2)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	paramBits, escape := uint(4), uint64(0xF)
+	if method == 1 {
+		paramBits, escape = 5, 0x1F
+	} else if method != 0 {
+		return BadFileError{"reserved residual coding method"}
+	}
+
+	partitionOrder, err := br.read(4)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	partitions := 1 << partitionOrder
+	if blockSize%partitions != 0 || blockSize/partitions < order {
+		return BadFileError{"invalid partition order"}
+	}
+
+	for p := 0; p < partitions; p++ {
+		n := blockSize / partitions
+		if p == 0 {
+			n -= order
+		}
+		part := residual[:n]
+		residual = residual[n:]
+
+		param, err := br.read(paramBits)
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+
+		if param == escape {
+			size, err := br.read(5)
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			for i := range part {
+				part[i], err = br.readSigned(uint(size))
+				if err != nil {
+					return io.ErrUnexpectedEOF
+				}
+			}
+			continue
+		}
+
+		k := uint(param)
+		for i := range part {
+			q, err := br.readUnary()
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			r, err := br.read(k)
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			u := q<<k | r
+			part[i] = int64(u>>1) ^ -int64(u&1)
+		}
+	}
+	return nil
+}
+
+func restoreFixed(samples []int32, order int, residual []int64) {
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		switch order {
+		case 1:
+			prediction = int64(samples[i-1])
+		case 2:
+			prediction = 2*int64(samples[i-1]) - int64(samples[i-2])
+		case 3:
+			prediction = 3*int64(samples[i-1]) - 3*int64(samples[i-2]) + int64(samples[i-3])
+		case 4:
+			prediction = 4*int64(samples[i-1]) - 6*int64(samples[i-2]) + 4*int64(samples[i-3]) - int64(samples[i-4])
+		}
+		samples[i] = int32(prediction + residual[i])
+	}
+}
+
+func restoreLPC(samples []int32, coeffs []int64, shift uint, residual []int64) {
+	order := len(coeffs)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += c * int64(samples[i-1-j])
+		}
+		samples[i] = int32(sum>>shift + residual[i])
+	}
+}
+
+// Undo inter-channel decorrelation of a stereo frame.
+func decorrelate(samples [][]int32, assignment int) {
+	switch assignment {
+	case leftSide:
+		left, side := samples[0], samples[1]
+		for i := range side {
+			side[i] = left[i] - side[i]
+		}
+	case sideRight:
+		side, right := samples[0], samples[1]
+		for i := range side {
+			side[i] += right[i]
+		}
+	case midSide:
+		mid, side := samples[0], samples[1]
+		for i := range mid {
+			m := int64(mid[i])<<1 | int64(side[i])&1
+			s := int64(side[i])
+			mid[i] = int32((m + s) >> 1)
+			side[i] = int32((m - s) >> 1)
+		}
+	}
+}
@@ -0,0 +1,665 @@
+package flacio
+
+import (
+	"crypto/md5"
+	"hash"
+	"io"
+	"math"
+)
+
+// Settings for a compression level, after the presets of the reference
+// encoder.
+type level struct {
+	blockSize         int
+	stereo            bool // try inter-channel decorrelation
+	maxFixedOrder     int
+	maxLPCOrder       int
+	maxPartitionOrder int
+}
+
+var levels = [...]level{
+	{1152, false, 2, 0, 3},
+	{1152, true, 2, 0, 3},
+	{1152, true, 4, 0, 3},
+	{4096, false, 4, 6, 4},
+	{4096, true, 4, 8, 4},
+	{4096, true, 4, 8, 5},
+	{4096, true, 4, 8, 6},
+	{4096, true, 4, 12, 6},
+	{4096, true, 4, 12, 8},
+}
+
+// Compression levels range from 0 (fastest) to MaxLevel (smallest).
+const (
+	DefaultLevel = 5
+	MaxLevel     = len(levels) - 1
+)
+
+// Precision of quantised LPC coefficients.
+const (
+	lpcPrecision     = 13
+	lpcPrecisionHigh = 15 // for samples wider than 16 bits
+)
+
+// An encoder writes FLAC frames to a file whose metadata it fills in once the
+// stream is complete.
+type encoder struct {
+	w     io.Writer
+	info  StreamInfo
+	level level
+
+	md5    hash.Hash
+	md5Buf []byte
+
+	frameNumber uint64
+	offset      int64 // of the next frame, relative to the first
+	frameStarts []SeekPoint
+
+	seekTableSize   int
+	seekTableOffset int64
+}
+
+func newEncoder(w io.Writer, info StreamInfo, lvl level, seekTableSize int, comments map[string]string) (e *encoder, err error) {
+	e = &encoder{
+		w:             w,
+		info:          info,
+		level:         lvl,
+		md5:           md5.New(),
+		seekTableSize: seekTableSize,
+	}
+
+	header := []byte("fLaC")
+	header = appendBlockHeader(header, blockStreamInfo, streamInfoSize, false)
+	header = append(header, info.bytes()...)
+	if seekTableSize > 0 {
+		header = appendBlockHeader(header, blockSeekTable, seekTableSize*seekPointSize, false)
+		e.seekTableOffset = int64(len(header))
+		header = append(header, seekTableBytes(nil, seekTableSize)...)
+	}
+	vc := vorbisCommentBytes("gosound", comments)
+	header = appendBlockHeader(header, blockVorbisComment, len(vc), true)
+	header = append(header, vc...)
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func appendBlockHeader(buf []byte, blockType byte, length int, last bool) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	return append(buf, blockType, byte(length>>16), byte(length>>8), byte(length))
+}
+
+// Encode and write one block of samples.
+func (e *encoder) writeFrame(block [][]int32) (err error) {
+	e.updateMD5(block)
+
+	frame := e.encodeFrame(block)
+	_, err = e.w.Write(frame)
+	if err != nil {
+		return err
+	}
+
+	size := len(frame)
+	if e.info.MinFrameSize == 0 || size < e.info.MinFrameSize {
+		e.info.MinFrameSize = size
+	}
+	if size > e.info.MaxFrameSize {
+		e.info.MaxFrameSize = size
+	}
+
+	e.frameStarts = append(e.frameStarts, SeekPoint{
+		Sample:  e.info.TotalSamples,
+		Offset:  e.offset,
+		Samples: len(block[0]),
+	})
+	e.offset += int64(size)
+	e.info.TotalSamples += int64(len(block[0]))
+	e.frameNumber++
+	return nil
+}
+
+func (e *encoder) updateMD5(block [][]int32) {
+	bytesPerSample := (e.info.BitsPerSample + 7) / 8
+	n := len(block[0]) * len(block) * bytesPerSample
+	if cap(e.md5Buf) < n {
+		e.md5Buf = make([]byte, n)
+	}
+	buf := e.md5Buf[:n]
+
+	i := 0
+	for j := range block[0] {
+		for _, channel := range block {
+			for k := 0; k < bytesPerSample; k++ {
+				buf[i] = byte(channel[j] >> uint(8*k))
+				i++
+			}
+		}
+	}
+	e.md5.Write(buf)
+}
+
+// Fill in STREAMINFO and the seek table now that the stream is complete. 'f'
+// is the file written to, with any buffering flushed.
+func (e *encoder) finish(f io.WriteSeeker) (err error) {
+	copy(e.info.MD5[:], e.md5.Sum(nil))
+
+	_, err = f.Seek(8, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(e.info.bytes())
+	if err != nil {
+		return err
+	}
+
+	if e.seekTableSize > 0 {
+		_, err = f.Seek(e.seekTableOffset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(seekTableBytes(e.seekPoints(), e.seekTableSize))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Choose frames spaced evenly through the stream as seek points.
+func (e *encoder) seekPoints() (points []SeekPoint) {
+	interval := e.info.TotalSamples / int64(e.seekTableSize)
+	next := int64(0)
+	for _, frame := range e.frameStarts {
+		if frame.Sample >= next && len(points) < e.seekTableSize {
+			points = append(points, frame)
+			next = frame.Sample + interval
+		}
+	}
+	return points
+}
+
+func (e *encoder) encodeFrame(block [][]int32) []byte {
+	bps := e.info.BitsPerSample
+	blockSize := len(block[0])
+
+	channels := make([][]int64, len(block))
+	for i, samples := range block {
+		channels[i] = make([]int64, blockSize)
+		for j, x := range samples {
+			channels[i][j] = int64(x)
+		}
+	}
+
+	assignment := len(block) - 1
+	var subframes []*subframe
+
+	if len(block) == 2 && e.level.stereo {
+		left, right := channels[0], channels[1]
+		mid := make([]int64, blockSize)
+		side := make([]int64, blockSize)
+		for i := range left {
+			mid[i] = (left[i] + right[i]) >> 1
+			side[i] = left[i] - right[i]
+		}
+
+		l := e.encodeSubframe(left, bps)
+		r := e.encodeSubframe(right, bps)
+		m := e.encodeSubframe(mid, bps)
+		s := e.encodeSubframe(side, bps+1)
+
+		subframes = []*subframe{l, r}
+		best := l.bits + r.bits
+		if l.bits+s.bits < best {
+			assignment, subframes, best = leftSide, []*subframe{l, s}, l.bits+s.bits
+		}
+		if s.bits+r.bits < best {
+			assignment, subframes, best = sideRight, []*subframe{s, r}, s.bits+r.bits
+		}
+		if m.bits+s.bits < best {
+			assignment, subframes = midSide, []*subframe{m, s}
+		}
+
+	} else {
+		for _, samples := range channels {
+			subframes = append(subframes, e.encodeSubframe(samples, bps))
+		}
+	}
+
+	bw := new(bitWriter)
+	e.writeFrameHeader(bw, blockSize, assignment)
+	for _, sf := range subframes {
+		sf.write(bw)
+	}
+	bw.align()
+	bw.write(uint64(crc16(bw.bytes())), 16)
+	return bw.bytes()
+}
+
+var sampleRateCodes = map[int]uint64{
+	88200:  1,
+	176400: 2,
+	192000: 3,
+	8000:   4,
+	16000:  5,
+	22050:  6,
+	24000:  7,
+	32000:  8,
+	44100:  9,
+	48000:  10,
+	96000:  11,
+}
+
+var sampleSizeCodes = map[int]uint64{
+	8:  1,
+	12: 2,
+	16: 4,
+	20: 5,
+	24: 6,
+}
+
+func (e *encoder) writeFrameHeader(bw *bitWriter, blockSize int, assignment int) {
+	bw.write(0x3FFE, 14)
+	bw.write(0, 1) // reserved
+	bw.write(0, 1) // fixed block size
+
+	var blockSizeCode uint64
+	switch {
+	case blockSize == 192:
+		blockSizeCode = 1
+	case blockSize == 576, blockSize == 1152, blockSize == 2304, blockSize == 4608:
+		blockSizeCode = 2 + uint64(math.Log2(float64(blockSize/576)))
+	case blockSize >= 256 && blockSize <= 32768 && blockSize&(blockSize-1) == 0:
+		blockSizeCode = 8 + uint64(math.Log2(float64(blockSize/256)))
+	case blockSize <= 256:
+		blockSizeCode = 6
+	default:
+		blockSizeCode = 7
+	}
+	bw.write(blockSizeCode, 4)
+
+	bw.write(sampleRateCodes[e.info.SampleRate], 4) // 0 means see STREAMINFO
+	bw.write(uint64(assignment), 4)
+	bw.write(sampleSizeCodes[e.info.BitsPerSample], 3)
+	bw.write(0, 1) // reserved
+
+	writeUTF8(bw, e.frameNumber)
+
+	switch blockSizeCode {
+	case 6:
+		bw.write(uint64(blockSize-1), 8)
+	case 7:
+		bw.write(uint64(blockSize-1), 16)
+	}
+
+	bw.write(uint64(crc8(bw.bytes())), 8)
+}
+
+// Write a frame number in the extended UTF-8 coding used by frame headers.
+func writeUTF8(bw *bitWriter, v uint64) {
+	if v < 0x80 {
+		bw.write(v, 8)
+		return
+	}
+
+	n := 2
+	for v >= 1<<uint(5*n+1) && n < 7 {
+		n++
+	}
+
+	lead := uint64(0xFF) << uint(8-n) & 0xFF
+	bw.write(lead|v>>uint(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		bw.write(0x80|v>>uint(6*i)&0x3F, 8)
+	}
+}
+
+// A subframe is one channel of a frame, encoded with the cheapest predictor
+// found.
+type subframe struct {
+	kind      int // subframe type code
+	bps       int
+	warmup    []int64
+	coeffs    []int64
+	precision int
+	shift     int
+	residual  []int64
+	rice      riceCoding
+	bits      int
+}
+
+func (sf *subframe) write(bw *bitWriter) {
+	bw.write(uint64(sf.kind)<<1, 8) // no wasted bits
+
+	switch {
+	case sf.kind == 0:
+		bw.writeSigned(sf.warmup[0], uint(sf.bps))
+	case sf.kind == 1:
+		for _, x := range sf.warmup {
+			bw.writeSigned(x, uint(sf.bps))
+		}
+	default:
+		for _, x := range sf.warmup {
+			bw.writeSigned(x, uint(sf.bps))
+		}
+		if sf.kind >= 32 {
+			bw.write(uint64(sf.precision-1), 4)
+			bw.writeSigned(int64(sf.shift), 5)
+			for _, c := range sf.coeffs {
+				bw.writeSigned(c, uint(sf.precision))
+			}
+		}
+		sf.rice.write(bw, sf.residual, len(sf.warmup))
+	}
+}
+
+func (e *encoder) encodeSubframe(samples []int64, bps int) (best *subframe) {
+	n := len(samples)
+
+	constant := true
+	for _, x := range samples {
+		if x != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return &subframe{kind: 0, bps: bps, warmup: samples[:1], bits: 8 + bps}
+	}
+
+	best = &subframe{kind: 1, bps: bps, warmup: samples, bits: 8 + n*bps}
+
+	residual := make([]int64, n)
+	for order := 0; order <= e.level.maxFixedOrder && order < n; order++ {
+		if !fixedResidual(samples, order, residual) {
+			continue
+		}
+		rice, bits, ok := chooseRice(residual, order, e.level.maxPartitionOrder)
+		bits += 8 + order*bps
+		if ok && bits < best.bits {
+			best = &subframe{
+				kind:     8 + order,
+				bps:      bps,
+				warmup:   samples[:order],
+				residual: append([]int64(nil), residual...),
+				rice:     rice,
+				bits:     bits,
+			}
+		}
+	}
+
+	maxOrder := e.level.maxLPCOrder
+	if maxOrder >= n {
+		maxOrder = n - 1
+	}
+	if maxOrder > 0 {
+		precision := lpcPrecision
+		if bps > 16 {
+			precision = lpcPrecisionHigh
+		}
+
+		for order, lpc := range levinsonDurbin(autocorrelation(samples, maxOrder)) {
+			order++
+			coeffs, shift := quantiseCoefficients(lpc, precision)
+			if !lpcResidual(samples, coeffs, shift, residual) {
+				continue
+			}
+			rice, bits, ok := chooseRice(residual, order, e.level.maxPartitionOrder)
+			bits += 8 + order*bps + 4 + 5 + order*precision
+			if ok && bits < best.bits {
+				best = &subframe{
+					kind:      31 + order,
+					bps:       bps,
+					warmup:    samples[:order],
+					coeffs:    coeffs,
+					precision: precision,
+					shift:     shift,
+					residual:  append([]int64(nil), residual...),
+					rice:      rice,
+					bits:      bits,
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// Largest residual magnitude allowed by the format.
+const maxResidual = 1<<31 - 1
+
+func fixedResidual(samples []int64, order int, residual []int64) (ok bool) {
+	for i := order; i < len(samples); i++ {
+		var r int64
+		switch order {
+		case 0:
+			r = samples[i]
+		case 1:
+			r = samples[i] - samples[i-1]
+		case 2:
+			r = samples[i] - 2*samples[i-1] + samples[i-2]
+		case 3:
+			r = samples[i] - 3*samples[i-1] + 3*samples[i-2] - samples[i-3]
+		case 4:
+			r = samples[i] - 4*samples[i-1] + 6*samples[i-2] - 4*samples[i-3] + samples[i-4]
+		}
+		if r > maxResidual || r < -maxResidual {
+			return false
+		}
+		residual[i] = r
+	}
+	return true
+}
+
+func lpcResidual(samples []int64, coeffs []int64, shift int, residual []int64) (ok bool) {
+	for i := len(coeffs); i < len(samples); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += c * samples[i-1-j]
+		}
+		r := samples[i] - sum>>uint(shift)
+		if r > maxResidual || r < -maxResidual {
+			return false
+		}
+		residual[i] = r
+	}
+	return true
+}
+
+// Autocorrelation of the samples under a Welch window, up to the given lag.
+func autocorrelation(samples []int64, maxLag int) (r []float64) {
+	n := len(samples)
+	x := make([]float64, n)
+	for i, s := range samples {
+		t := (2*float64(i) - float64(n-1)) / float64(n+1)
+		x[i] = float64(s) * (1 - t*t)
+	}
+
+	r = make([]float64, maxLag+1)
+	for lag := range r {
+		for i := lag; i < n; i++ {
+			r[lag] += x[i] * x[i-lag]
+		}
+	}
+	return r
+}
+
+// Solve for the predictor coefficients of every order up to len(r)-1 using the
+// Levinson-Durbin recursion. lpcs[k] has k+1 coefficients, where coefficient j
+// applies to the sample j+1 steps back.
+func levinsonDurbin(r []float64) (lpcs [][]float64) {
+	maxOrder := len(r) - 1
+	if r[0] == 0 {
+		return nil
+	}
+
+	a := make([]float64, maxOrder)
+	err := r[0]
+	for k := 0; k < maxOrder; k++ {
+		acc := r[k+1]
+		for j := 0; j < k; j++ {
+			acc -= a[j] * r[k-j]
+		}
+		reflection := acc / err
+
+		prev := append([]float64(nil), a[:k]...)
+		a[k] = reflection
+		for j := 0; j < k; j++ {
+			a[j] = prev[j] - reflection*prev[k-1-j]
+		}
+
+		err *= 1 - reflection*reflection
+		lpcs = append(lpcs, append([]float64(nil), a[:k+1]...))
+		if err <= 0 {
+			break
+		}
+	}
+	return lpcs
+}
+
+// Quantise coefficients to the given precision, choosing the largest shift
+// that keeps them in range and carrying the rounding error forward.
+func quantiseCoefficients(lpc []float64, precision int) (coeffs []int64, shift int) {
+	cmax := 0.0
+	for _, c := range lpc {
+		cmax = math.Max(cmax, math.Abs(c))
+	}
+
+	shift = precision - 1
+	if cmax > 0 {
+		_, exp := math.Frexp(cmax)
+		shift = precision - 1 - exp
+	}
+	if shift > 15 {
+		shift = 15
+	} else if shift < 0 {
+		shift = 0
+	}
+
+	limit := int64(1)<<uint(precision-1) - 1
+	coeffs = make([]int64, len(lpc))
+	carry := 0.0
+	for i, c := range lpc {
+		v := c*float64(int64(1)<<uint(shift)) + carry
+		q := int64(math.Floor(v + 0.5))
+		if q > limit {
+			q = limit
+		} else if q < -limit-1 {
+			q = -limit - 1
+		}
+		carry = v - float64(q)
+		coeffs[i] = q
+	}
+	return coeffs, shift
+}
+
+// Partitioned Rice coding of a residual.
+type riceCoding struct {
+	method         int // 0 for 4-bit parameters, 1 for 5-bit
+	partitionOrder int
+	params         []uint
+}
+
+// Find the partition order and parameters that code the residual following
+// 'order' warm-up samples in the fewest bits.
+func chooseRice(residual []int64, order int, maxPartitionOrder int) (best riceCoding, bestBits int, ok bool) {
+	n := len(residual)
+	u := make([]uint64, n)
+	for i := order; i < n; i++ {
+		u[i] = uint64(residual[i]<<1 ^ residual[i]>>63)
+	}
+
+	bestBits = math.MaxInt64
+	for po := 0; po <= maxPartitionOrder; po++ {
+		partitions := 1 << uint(po)
+		if n%partitions != 0 || n/partitions < order {
+			break
+		}
+
+		rc := riceCoding{partitionOrder: po, params: make([]uint, partitions)}
+		bits := 2 + 4
+		for p := 0; p < partitions; p++ {
+			start := p * n / partitions
+			if p == 0 {
+				start = order
+			}
+			end := (p + 1) * n / partitions
+
+			k, cost := riceParameter(u[start:end])
+			rc.params[p] = k
+			if k > 14 {
+				rc.method = 1
+			}
+			bits += cost
+		}
+		if rc.method == 1 {
+			bits += 5 * partitions
+		} else {
+			bits += 4 * partitions
+		}
+
+		if bits < bestBits {
+			best, bestBits, ok = rc, bits, true
+		}
+	}
+	return best, bestBits, ok
+}
+
+// The cheapest Rice parameter for the values, and the bits they then take.
+func riceParameter(u []uint64) (k uint, bits int) {
+	if len(u) == 0 {
+		return 0, 0
+	}
+
+	var sum uint64
+	for _, x := range u {
+		sum += x
+	}
+	guess := uint(0)
+	if mean := sum / uint64(len(u)); mean > 1 {
+		guess = uint(math.Log2(float64(mean))) - 1
+	}
+
+	bits = math.MaxInt64
+	for candidate := guess; candidate <= guess+2 && candidate <= 30; candidate++ {
+		cost := len(u) * int(candidate+1)
+		for _, x := range u {
+			cost += int(x >> candidate)
+		}
+		if cost < bits {
+			k, bits = candidate, cost
+		}
+	}
+	return k, bits
+}
+
+func (rc riceCoding) write(bw *bitWriter, residual []int64, order int) {
+	paramBits := uint(4)
+	if rc.method == 1 {
+		paramBits = 5
+	}
+	bw.write(uint64(rc.method), 2)
+	bw.write(uint64(rc.partitionOrder), 4)
+
+	n := len(residual)
+	partitions := len(rc.params)
+	for p, k := range rc.params {
+		bw.write(uint64(k), paramBits)
+
+		start := p * n / partitions
+		if p == 0 {
+			start = order
+		}
+		end := (p + 1) * n / partitions
+		for _, r := range residual[start:end] {
+			x := uint64(r<<1 ^ r>>63)
+			bw.writeUnary(x >> k)
+			bw.write(x, k)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package flacio reads and writes FLAC files without cgo.
+//
+// Any stream within the format's subset can be decoded; the MD5 signature is
+// verified when a stream is read from start to end. The encoder writes
+// fixed-size blocks using fixed or LPC prediction, with inter-channel
+// decorrelation for stereo, and records a seek table and Vorbis comments.
+package flacio
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+	"strings"
+)
+
+type Input struct {
+	Filename   string
+	BufferSize int
+
+	// Part of the file to read. The zero Region reads the whole file once.
+	// The MD5 signature is only verified when a whole file is read once.
+	Region soundio.Region
+}
+
+// Info reads the format and length of the file without streaming it.
+func (si Input) Info() (info soundio.FileInfo, err error) {
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	d, err := NewDecoder(f)
+	if err != nil {
+		return info, err
+	}
+
+	return soundio.FileInfo{
+		SampleRate: float64(d.Info.SampleRate),
+		Channels:   d.Info.Channels,
+		Frames:     d.frames(),
+		Format:     fmt.Sprintf("FLAC %d-bit", d.Info.BitsPerSample),
+	}, nil
+}
+
+// Metadata reads the metadata of the file without streaming it.
+func (si Input) Metadata() (md soundio.Metadata, err error) {
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		return md, err
+	}
+	defer f.Close()
+
+	d, err := NewDecoder(f)
+	if err != nil {
+		return md, err
+	}
+
+	return d.Metadata(), nil
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	channels = make([]chan float64, d.Info.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			err2 := f.Close()
+			if err2 != nil {
+				errChan <- err2
+			}
+
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		start, end := si.Region.Bounds(d.frames())
+		if start == end {
+			return
+		}
+
+		first := true
+		err := si.Region.Repeat(func() (frames int64, err error) {
+			if start > 0 || !first {
+				err = d.SeekSample(start)
+				if err != nil {
+					return 0, err
+				}
+			}
+			first = false
+
+			length := int64(-1)
+			if end >= 0 {
+				length = end - start
+			}
+			return streamFrames(d, channels, length)
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return float64(d.Info.SampleRate), channels, errChan
+}
+
+// Decode up to 'length' frames from 'd', or until the end of the stream if
+// 'length' is negative, sending the samples, scaled to [-1, 1), to 'channels'.
+// Returns the number of frames sent.
+func streamFrames(d *Decoder, channels []chan float64, length int64) (frames int64, err error) {
+	scale := 1 / float64(uint64(1)<<uint(d.Info.BitsPerSample-1))
+
+	for length != 0 {
+		samples, err := d.ReadFrame()
+		if err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return frames, err
+		}
+
+		n := len(samples[0])
+		if length >= 0 && int64(n) > length {
+			n = int(length)
+		}
+		length -= int64(n)
+
+		for i := 0; i < n; i++ {
+			for c, channel := range channels {
+				channel <- float64(samples[c][i]) * scale
+			}
+		}
+		frames += int64(n)
+	}
+
+	return frames, nil
+}
+
+type Output struct {
+	Filename   string
+	BufferSize int
+
+	// Sample size, from 4 to 24 bits.
+	BitsPerSample int
+
+	// Dither applied when converting to integer samples.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	// Compression level, from 0 to MaxLevel.
+	Level int
+
+	// Vorbis comments to write, keyed by field name (such as "TITLE").
+	Comments map[string]string
+
+	// Metadata to write as Vorbis comments, in the fields read by
+	// Decoder.Metadata. Comments take precedence over it. Only the first loop
+	// is written, and the broadcast extension is not written.
+	Metadata soundio.Metadata
+
+	// Number of seek table entries to write. If zero, no seek table is
+	// written.
+	SeekPoints int
+}
+
+func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	if so.BitsPerSample < 4 || so.BitsPerSample > 24 {
+		return fmt.Errorf("flacio: unsupported sample size: %d bits", so.BitsPerSample)
+	}
+	if so.Level < 0 || so.Level > MaxLevel {
+		return fmt.Errorf("flacio: invalid compression level: %d", so.Level)
+	}
+	if len(channels) < 1 || len(channels) > 8 {
+		return fmt.Errorf("flacio: unsupported number of channels: %d", len(channels))
+	}
+
+	lvl := levels[so.Level]
+	info := StreamInfo{
+		MinBlockSize:  lvl.blockSize,
+		MaxBlockSize:  lvl.blockSize,
+		SampleRate:    int(sampleRate),
+		Channels:      len(channels),
+		BitsPerSample: so.BitsPerSample,
+	}
+
+	f, err := os.Create(so.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	comments := metadataComments(so.Metadata, sampleRate)
+	for field, value := range so.Comments {
+		comments[strings.ToUpper(field)] = value
+	}
+
+	e, err := newEncoder(w, info, lvl, so.SeekPoints, comments)
+	if err != nil {
+		return err
+	}
+
+	block := make([][]int32, len(channels))
+	for i := range block {
+		block[i] = make([]int32, 0, lvl.blockSize)
+	}
+
+	q := soundio.NewQuantizer(soundio.Int32, so.Dither, len(channels))
+	q.Bits = so.BitsPerSample
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		for i, x := range buffer {
+			c := i % len(channels)
+			block[c] = append(block[c], q.Quantize(x, c))
+
+			if c == len(channels)-1 && len(block[c]) == lvl.blockSize {
+				err = e.writeFrame(block)
+				if err != nil {
+					return err
+				}
+				for j := range block {
+					block[j] = block[j][:0]
+				}
+			}
+		}
+		il.Release(buffer)
+	}
+
+	err = il.Err()
+	if err != nil {
+		return err
+	}
+
+	if len(block[0]) > 0 {
+		err = e.writeFrame(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	return e.finish(f)
+}
@@ -0,0 +1,109 @@
+package flacio
+
+import (
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Two channels of tones at different frequencies, quantised to 'bits' so that
+// they survive lossless compression exactly.
+func tones(frames int, bits int) memio.Input {
+	scale := math.Pow(2, float64(bits-1))
+	channels := [][]float64{make([]float64, frames), make([]float64, frames)}
+	for i := 0; i < frames; i++ {
+		channels[0][i] = math.Floor(0.5*math.Sin(2*math.Pi*440*float64(i)/44100)*scale) / scale
+		channels[1][i] = math.Floor(0.25*math.Sin(2*math.Pi*1000*float64(i)/44100)*scale) / scale
+	}
+	return memio.Input{SampleRate: 44100, Channels: channels, BufferSize: 64}
+}
+
+func tempFile(t *testing.T) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "flacio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "test.flac"), func() { os.RemoveAll(dir) }
+}
+
+func TestRoundTrip(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	for _, bits := range []int{8, 16, 24} {
+		for _, level := range []int{0, DefaultLevel, MaxLevel} {
+			in := tones(10001, bits)
+			so := Output{Filename: filename, BufferSize: 64, BitsPerSample: bits, Level: level, SeekPoints: 10}
+			if err := soundio.Copy(so, in); err != nil {
+				t.Fatal(err)
+			}
+
+			out := new(memio.Output)
+			if err := soundio.Copy(out, Input{Filename: filename, BufferSize: 64}); err != nil {
+				t.Fatalf("%d bits, level %d: %s", bits, level, err)
+			}
+
+			if out.SampleRate != in.SampleRate || len(out.Channels) != 2 {
+				t.Fatalf("%d bits, level %d: got %d channels at %v Hz", bits, level, len(out.Channels), out.SampleRate)
+			}
+			for c, buffer := range in.Channels {
+				if len(out.Channels[c]) != len(buffer) {
+					t.Fatalf("%d bits, level %d: channel %d has %d samples, want %d", bits, level, c, len(out.Channels[c]), len(buffer))
+				}
+				for i, x := range buffer {
+					if out.Channels[c][i] != x {
+						t.Errorf("%d bits, level %d: channel %d sample %d is %v, want %v", bits, level, c, i, out.Channels[c][i], x)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	filename, cleanup := tempFile(t)
+	defer cleanup()
+
+	key := music.Scale{Root: music.MakeNote(music.E, 4), Intervals: music.Minor}
+	md := soundio.Metadata{
+		Title:   "Title",
+		Artist:  "Artist",
+		Comment: "Comment",
+		BPM:     93.5,
+		Key:     &key,
+		Loops:   []soundio.Loop{{Start: 100, End: 900}},
+		Markers: []soundio.Marker{{Position: 441, Label: "one"}},
+	}
+
+	so := Output{Filename: filename, BufferSize: 64, BitsPerSample: 16, Level: DefaultLevel, Metadata: md}
+	if err := soundio.Copy(so, tones(1000, 16)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Input{Filename: filename}.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != md.Title || got.Artist != md.Artist || got.Comment != md.Comment {
+		t.Errorf("got tags %q, %q, %q", got.Title, got.Artist, got.Comment)
+	}
+	if got.BPM != md.BPM {
+		t.Errorf("got tempo %v, want %v", got.BPM, md.BPM)
+	}
+	if got.Key == nil || got.Key.Root.Letter() != music.E || got.Key.Mode() != "minor" {
+		t.Errorf("got key %v, want Em", got.Key)
+	}
+	if len(got.Loops) != 1 || got.Loops[0] != md.Loops[0] {
+		t.Errorf("got loops %v, want %v", got.Loops, md.Loops)
+	}
+	if len(got.Markers) != 1 || got.Markers[0] != md.Markers[0] {
+		t.Errorf("got markers %v, want %v", got.Markers, md.Markers)
+	}
+}
@@ -0,0 +1,252 @@
+package flacio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	blockStreamInfo    = 0
+	blockPadding       = 1
+	blockSeekTable     = 3
+	blockVorbisComment = 4
+
+	streamInfoSize = 34
+	seekPointSize  = 18
+
+	// Sample number of an unused seek table entry.
+	placeholderPoint = 0xFFFFFFFFFFFFFFFF
+)
+
+// BadFileError is returned when a file is not a FLAC stream that can be
+// decoded, or fails a checksum.
+type BadFileError struct {
+	Reason string
+}
+
+func (err BadFileError) Error() string {
+	return fmt.Sprintf("flacio: %s", err.Reason)
+}
+
+// StreamInfo holds the properties of a stream given by its STREAMINFO block.
+type StreamInfo struct {
+	MinBlockSize  int
+	MaxBlockSize  int
+	MinFrameSize  int // zero if unknown
+	MaxFrameSize  int // zero if unknown
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+
+	// Number of samples per channel, or zero if unknown.
+	TotalSamples int64
+
+	// MD5 signature of the unencoded audio, or all zero if unknown.
+	MD5 [16]byte
+}
+
+// A SeekPoint gives the byte offset, relative to the first frame, of the frame
+// starting at a given sample.
+type SeekPoint struct {
+	Sample  int64
+	Offset  int64
+	Samples int
+}
+
+func parseStreamInfo(data []byte) (info StreamInfo, err error) {
+	if len(data) < streamInfoSize {
+		return info, BadFileError{"short STREAMINFO block"}
+	}
+
+	info.MinBlockSize = int(binary.BigEndian.Uint16(data[0:]))
+	info.MaxBlockSize = int(binary.BigEndian.Uint16(data[2:]))
+	info.MinFrameSize = int(uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6]))
+	info.MaxFrameSize = int(uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9]))
+
+	x := binary.BigEndian.Uint64(data[10:])
+	info.SampleRate = int(x >> 44)
+	info.Channels = int(x>>41&0x7) + 1
+	info.BitsPerSample = int(x>>36&0x1F) + 1
+	info.TotalSamples = int64(x & 0xFFFFFFFFF)
+	copy(info.MD5[:], data[18:34])
+
+	if info.SampleRate == 0 {
+		return info, BadFileError{"invalid sample rate"}
+	}
+	if info.BitsPerSample < 4 || info.BitsPerSample > 32 {
+		return info, BadFileError{"unsupported sample size"}
+	}
+	return info, nil
+}
+
+func (info StreamInfo) bytes() []byte {
+	data := make([]byte, streamInfoSize)
+	binary.BigEndian.PutUint16(data[0:], uint16(info.MinBlockSize))
+	binary.BigEndian.PutUint16(data[2:], uint16(info.MaxBlockSize))
+	data[4], data[5], data[6] = byte(info.MinFrameSize>>16), byte(info.MinFrameSize>>8), byte(info.MinFrameSize)
+	data[7], data[8], data[9] = byte(info.MaxFrameSize>>16), byte(info.MaxFrameSize>>8), byte(info.MaxFrameSize)
+
+	x := uint64(info.SampleRate)<<44 |
+		uint64(info.Channels-1)<<41 |
+		uint64(info.BitsPerSample-1)<<36 |
+		uint64(info.TotalSamples)&0xFFFFFFFFF
+	binary.BigEndian.PutUint64(data[10:], x)
+	copy(data[18:], info.MD5[:])
+	return data
+}
+
+func parseSeekTable(data []byte) (points []SeekPoint) {
+	for len(data) >= seekPointSize {
+		sample := binary.BigEndian.Uint64(data[0:])
+		if sample != placeholderPoint {
+			points = append(points, SeekPoint{
+				Sample:  int64(sample),
+				Offset:  int64(binary.BigEndian.Uint64(data[8:])),
+				Samples: int(binary.BigEndian.Uint16(data[16:])),
+			})
+		}
+		data = data[seekPointSize:]
+	}
+	return points
+}
+
+// Encode a seek table of 'size' entries, filling any not used by 'points'
+// with placeholders.
+func seekTableBytes(points []SeekPoint, size int) []byte {
+	data := make([]byte, size*seekPointSize)
+	for i := 0; i < size; i++ {
+		entry := data[i*seekPointSize:]
+		if i < len(points) {
+			binary.BigEndian.PutUint64(entry[0:], uint64(points[i].Sample))
+			binary.BigEndian.PutUint64(entry[8:], uint64(points[i].Offset))
+			binary.BigEndian.PutUint16(entry[16:], uint16(points[i].Samples))
+		} else {
+			binary.BigEndian.PutUint64(entry[0:], placeholderPoint)
+		}
+	}
+	return data
+}
+
+// Parse a VORBIS_COMMENT block. Field names are upper-cased; where a field
+// appears more than once, the values are joined with newlines.
+func parseVorbisComment(data []byte) (vendor string, comments map[string]string, err error) {
+	bad := BadFileError{"malformed VORBIS_COMMENT block"}
+
+	readString := func() (s string, ok bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(len(data)-4) < uint64(n) {
+			return "", false
+		}
+		s = string(data[4 : 4+n])
+		data = data[4+n:]
+		return s, true
+	}
+
+	vendor, ok := readString()
+	if !ok || len(data) < 4 {
+		return "", nil, bad
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	comments = make(map[string]string)
+	for i := uint32(0); i < count; i++ {
+		s, ok := readString()
+		if !ok {
+			return "", nil, bad
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			continue
+		}
+		key := strings.ToUpper(s[:eq])
+		if prev, ok := comments[key]; ok {
+			comments[key] = prev + "\n" + s[eq+1:]
+		} else {
+			comments[key] = s[eq+1:]
+		}
+	}
+	return vendor, comments, nil
+}
+
+func vorbisCommentBytes(vendor string, comments map[string]string) []byte {
+	keys := make([]string, 0, len(comments))
+	for key := range comments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := new(bytes.Buffer)
+	writeString := func(s string) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+
+	writeString(vendor)
+	binary.Write(buf, binary.LittleEndian, uint32(len(keys)))
+	for _, key := range keys {
+		writeString(strings.ToUpper(key) + "=" + comments[key])
+	}
+	return buf.Bytes()
+}
+
+// Read the "fLaC" marker and the metadata blocks that follow it, returning
+// the number of bytes consumed.
+func (d *Decoder) readMetadata(r io.Reader) (n int64, err error) {
+	var marker [4]byte
+	_, err = io.ReadFull(r, marker[:])
+	if err != nil {
+		return 0, err
+	}
+	if string(marker[:]) != "fLaC" {
+		return 0, BadFileError{"not a FLAC stream"}
+	}
+	n = 4
+
+	haveInfo := false
+	for last := false; !last; {
+		var header [4]byte
+		_, err = io.ReadFull(r, header[:])
+		if err != nil {
+			return n, err
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		data := make([]byte, length)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return n, err
+		}
+		n += 4 + int64(length)
+
+		switch blockType {
+		case blockStreamInfo:
+			d.Info, err = parseStreamInfo(data)
+			if err != nil {
+				return n, err
+			}
+			haveInfo = true
+		case blockSeekTable:
+			d.SeekTable = parseSeekTable(data)
+		case blockVorbisComment:
+			d.Vendor, d.Comments, err = parseVorbisComment(data)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if !haveInfo {
+		return n, BadFileError{"missing STREAMINFO block"}
+	}
+	return n, nil
+}
@@ -0,0 +1,118 @@
+package flacio
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata returns the metadata held in the stream's Vorbis comments: the
+// TITLE, ARTIST, COMMENT (or DESCRIPTION), BPM and KEY (or INITIALKEY) fields,
+// a loop in LOOPSTART and LOOPLENGTH, and markers as CHAPTERxxx and
+// CHAPTERxxxNAME fields.
+func (d *Decoder) Metadata() (md soundio.Metadata) {
+	c := d.Comments
+	sampleRate := float64(d.Info.SampleRate)
+
+	md.Title = c["TITLE"]
+	md.Artist = c["ARTIST"]
+	md.Comment = c["COMMENT"]
+	if md.Comment == "" {
+		md.Comment = c["DESCRIPTION"]
+	}
+
+	md.BPM, _ = strconv.ParseFloat(strings.TrimSpace(c["BPM"]), 64)
+
+	keyText := c["KEY"]
+	if keyText == "" {
+		keyText = c["INITIALKEY"]
+	}
+	if key, err := soundio.ParseKey(keyText); err == nil {
+		md.Key = &key
+	}
+
+	start, err1 := strconv.ParseInt(c["LOOPSTART"], 10, 64)
+	length, err2 := strconv.ParseInt(c["LOOPLENGTH"], 10, 64)
+	if err1 == nil && err2 == nil && length > 0 {
+		md.Loops = []soundio.Loop{{Start: start, End: start + length}}
+	}
+
+	for i := 0; i < 1000; i++ {
+		field := fmt.Sprintf("CHAPTER%03d", i)
+		t, ok := parseChapterTime(c[field])
+		if !ok {
+			break
+		}
+		md.Markers = append(md.Markers, soundio.Marker{
+			Position: soundio.FrameAt(t, sampleRate),
+			Label:    c[field+"NAME"],
+		})
+	}
+
+	return md
+}
+
+// Vorbis comments holding the fields of 'md' that FLAC can store, as read by
+// Decoder.Metadata. Marker positions are rounded to the nearest millisecond.
+func metadataComments(md soundio.Metadata, sampleRate float64) (comments map[string]string) {
+	comments = make(map[string]string)
+	set := func(field, value string) {
+		if value != "" {
+			comments[field] = value
+		}
+	}
+
+	set("TITLE", md.Title)
+	set("ARTIST", md.Artist)
+	set("COMMENT", md.Comment)
+	if md.BPM > 0 {
+		set("BPM", strconv.FormatFloat(md.BPM, 'f', -1, 64))
+	}
+	if md.Key != nil {
+		set("KEY", soundio.FormatKey(*md.Key))
+	}
+
+	if len(md.Loops) > 0 {
+		loop := md.Loops[0]
+		set("LOOPSTART", strconv.FormatInt(loop.Start, 10))
+		set("LOOPLENGTH", strconv.FormatInt(loop.End-loop.Start, 10))
+	}
+
+	for i, marker := range md.Markers {
+		if i >= 1000 {
+			break
+		}
+		field := fmt.Sprintf("CHAPTER%03d", i)
+		t := time.Duration(float64(marker.Position) / sampleRate * float64(time.Second))
+		set(field, formatChapterTime(t))
+		set(field+"NAME", marker.Label)
+	}
+
+	return comments
+}
+
+// Format a chapter time as HH:MM:SS.mmm.
+func formatChapterTime(t time.Duration) string {
+	ms := int64((t + time.Millisecond/2) / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Parse a chapter time of the form HH:MM:SS.sss.
+func parseChapterTime(s string) (t time.Duration, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+
+	t = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	return t + time.Duration(sec*float64(time.Second)+0.5), true
+}
@@ -0,0 +1,181 @@
+package soundio
+
+import (
+	"errors"
+)
+
+// LengthPolicy determines how an Interlacer handles channels of different
+// lengths.
+type LengthPolicy int
+
+const (
+	// Pad channels that end early with zeros until the longest ends.
+	PadShorter LengthPolicy = iota
+
+	// Stop when the shortest channel ends, discarding the rest of the others.
+	TruncateToShortest
+
+	// Stop with ErrLengthMismatch if the channels do not all end together.
+	RequireEqualLength
+)
+
+// ErrLengthMismatch is reported by an Interlacer using RequireEqualLength when
+// its channels have different lengths.
+var ErrLengthMismatch = errors.New("soundio: channels have different lengths")
+
+// DefaultBufferSize is the number of frames per buffer used by NewInterlacer,
+// and by the inputs and outputs built on it, when no buffer size is given.
+const DefaultBufferSize = 1024
+
+// An Interlacer reads frames from a set of channels and groups them into
+// buffers of interleaved samples.
+type Interlacer struct {
+	Channels   []chan float64
+	BufferSize int // in frames
+	Policy     LengthPolicy
+
+	// Number of frames returned so far.
+	Frames int64
+
+	closed    []bool
+	numClosed int
+	done      bool
+	err       error
+	free      [][]float64
+}
+
+// NewInterlacer creates an Interlacer reading from 'channels'. If 'bufferSize'
+// is not positive, DefaultBufferSize is used.
+func NewInterlacer(channels []chan float64, bufferSize int, policy LengthPolicy) (il *Interlacer) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Interlacer{
+		Channels:   channels,
+		BufferSize: bufferSize,
+		Policy:     policy,
+		closed:     make([]bool, len(channels)),
+	}
+}
+
+// Next returns the next buffer of interleaved frames, or nil once the
+// channels have ended. Only the last buffer may hold fewer than BufferSize
+// frames. Once Next returns nil, Err reports whether the channels ended
+// cleanly.
+func (il *Interlacer) Next() (buffer []float64) {
+	if il.done || len(il.Channels) == 0 {
+		return nil
+	}
+
+	n := len(il.Channels)
+	if len(il.free) > 0 {
+		buffer = il.free[len(il.free)-1][:0]
+		il.free = il.free[:len(il.free)-1]
+	} else {
+		buffer = make([]float64, 0, n*il.BufferSize)
+	}
+
+	for len(buffer) < n*il.BufferSize {
+		if !il.readFrame(buffer[len(buffer) : len(buffer)+n]) {
+			il.done = true
+			break
+		}
+		buffer = buffer[:len(buffer)+n]
+		il.Frames++
+	}
+
+	if len(buffer) == 0 {
+		return nil
+	}
+	return buffer
+}
+
+// Read one frame into 'frame', returning false if there are no more.
+func (il *Interlacer) readFrame(frame []float64) bool {
+	newlyClosed := 0
+
+	for i, channel := range il.Channels {
+		if il.closed[i] {
+			frame[i] = 0
+			continue
+		}
+
+		x, ok := <-channel
+		if !ok {
+			il.closed[i] = true
+			il.numClosed++
+			newlyClosed++
+		}
+		frame[i] = x
+	}
+
+	switch {
+	case il.numClosed == len(il.Channels):
+		if il.Policy == RequireEqualLength && newlyClosed != len(il.Channels) {
+			il.err = ErrLengthMismatch
+		}
+		return false
+
+	case il.numClosed > 0 && il.Policy != PadShorter:
+		if il.Policy == RequireEqualLength {
+			il.err = ErrLengthMismatch
+		}
+		il.drain()
+		return false
+	}
+
+	return true
+}
+
+// Discard the rest of the channels that are still open, so that the
+// goroutines producing them can finish.
+func (il *Interlacer) drain() {
+	for i, channel := range il.Channels {
+		if !il.closed[i] {
+			go func(channel chan float64) {
+				for _ = range channel {
+				}
+			}(channel)
+		}
+	}
+}
+
+// Release returns a buffer obtained from Next so that it can be reused.
+func (il *Interlacer) Release(buffer []float64) {
+	il.free = append(il.free, buffer)
+}
+
+// Err returns ErrLengthMismatch if the channels ended at different times
+// under RequireEqualLength, or nil otherwise.
+func (il *Interlacer) Err() error {
+	return il.err
+}
+
+// Interlace reads frames from 'channels' and sends them in buffers of up to
+// 'bufferSize' interleaved frames. Shorter channels are padded with zeros
+// until the longest ends.
+func Interlace(channels []chan float64, bufferSize int) (bufferChan chan []float64) {
+	bufferChan = make(chan []float64)
+
+	go func() {
+		defer close(bufferChan)
+
+		il := NewInterlacer(channels, bufferSize, PadShorter)
+		for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+			bufferChan <- buffer
+		}
+	}()
+
+	return bufferChan
+}
+
+// Deinterlace sends the frames of an interleaved buffer to 'channels'. The
+// buffer should hold a whole number of frames.
+func Deinterlace(buffer []float64, channels []chan float64) {
+	n := len(channels)
+	for i := 0; i+n <= len(buffer); i += n {
+		for j, channel := range channels {
+			channel <- buffer[i+j]
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package soundio
+
+import (
+	"testing"
+)
+
+// A zero buffer size must still deliver every frame.
+func TestInterlacerDefaultBufferSize(t *testing.T) {
+	const frames = 3000
+	channels := []chan float64{make(chan float64, frames), make(chan float64, frames)}
+	for i := 0; i < frames; i++ {
+		channels[0] <- float64(i)
+		channels[1] <- -float64(i)
+	}
+	close(channels[0])
+	close(channels[1])
+
+	il := NewInterlacer(channels, 0, RequireEqualLength)
+	if il.BufferSize != DefaultBufferSize {
+		t.Errorf("got buffer size %d, want %d", il.BufferSize, DefaultBufferSize)
+	}
+
+	n := 0
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		for i := 0; i < len(buffer); i += 2 {
+			if buffer[i] != float64(n) || buffer[i+1] != -float64(n) {
+				t.Fatalf("frame %d is %v, want [%d, %d]", n, buffer[i:i+2], n, -n)
+			}
+			n++
+		}
+		il.Release(buffer)
+	}
+	if err := il.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != frames || il.Frames != frames {
+		t.Errorf("got %d frames (%d counted), want %d", n, il.Frames, frames)
+	}
+}
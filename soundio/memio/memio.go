@@ -0,0 +1,115 @@
+// Package memio provides sound inputs and outputs backed by memory, for tests
+// and offline processing, and an output that discards its input.
+package memio
+
+import (
+	"github.com/kierdavis/gosound/soundio"
+	"sync"
+	"time"
+)
+
+// Input replays buffers of samples, one per channel.
+type Input struct {
+	SampleRate float64
+	Channels   [][]float64
+	BufferSize int
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+	close(errChan)
+
+	channels = make([]chan float64, len(si.Channels))
+	for i, buffer := range si.Channels {
+		channels[i] = make(chan float64, si.BufferSize)
+
+		go func(channel chan float64, buffer []float64) {
+			defer close(channel)
+			for _, x := range buffer {
+				channel <- x
+			}
+		}(channels[i], buffer)
+	}
+
+	return si.SampleRate, channels, errChan
+}
+
+// Output records everything written to it. Since Write fills in the fields,
+// it must be called through a pointer.
+type Output struct {
+	SampleRate float64
+	Channels   [][]float64
+}
+
+func (so *Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	so.SampleRate = sampleRate
+	so.Channels = make([][]float64, len(channels))
+
+	// Every channel must be read concurrently, since they may share upstream
+	// forks.
+	var wg sync.WaitGroup
+	for i, channel := range channels {
+		wg.Add(1)
+		go func(i int, channel chan float64) {
+			var buffer []float64
+			for x := range channel {
+				buffer = append(buffer, x)
+			}
+			so.Channels[i] = buffer
+			wg.Done()
+		}(i, channel)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Input returns an Input that replays the recorded buffers.
+func (so *Output) Input(bufferSize int) Input {
+	return Input{
+		SampleRate: so.SampleRate,
+		Channels:   so.Channels,
+		BufferSize: bufferSize,
+	}
+}
+
+// NullOutput discards everything written to it, recording how many frames
+// were written and how long it took. Since Write fills in the fields, it must
+// be called through a pointer.
+type NullOutput struct {
+	BufferSize int
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	SampleRate float64
+	Frames     int64
+	Elapsed    time.Duration
+}
+
+func (so *NullOutput) Write(sampleRate float64, channels []chan float64) (err error) {
+	startTime := time.Now()
+	so.SampleRate = sampleRate
+
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		il.Release(buffer)
+	}
+	so.Frames = il.Frames
+
+	so.Elapsed = time.Since(startTime)
+	return il.Err()
+}
+
+// Throughput returns the number of frames written per second of real time.
+func (so *NullOutput) Throughput() float64 {
+	return float64(so.Frames) / so.Elapsed.Seconds()
+}
+
+// Ratio returns the duration of the audio written divided by the real time
+// taken; a value above 1 means the audio was generated faster than real time.
+func (so *NullOutput) Ratio() float64 {
+	return so.Throughput() / so.SampleRate
+}
@@ -0,0 +1,63 @@
+package memio
+
+import (
+	"github.com/kierdavis/gosound/soundio"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	in := Input{
+		SampleRate: 48000,
+		Channels:   [][]float64{{1, 2, 3}, {-1, -2, -3}},
+		BufferSize: 1,
+	}
+
+	out := new(Output)
+	if err := soundio.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+
+	again := new(Output)
+	if err := soundio.Copy(again, out.Input(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if again.SampleRate != 48000 {
+		t.Errorf("got sample rate %v, want 48000", again.SampleRate)
+	}
+	if len(again.Channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(again.Channels))
+	}
+	for c, want := range in.Channels {
+		got := again.Channels[c]
+		if len(got) != len(want) {
+			t.Fatalf("channel %d: got %v, want %v", c, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("channel %d: got %v, want %v", c, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestNullOutput(t *testing.T) {
+	in := Input{
+		SampleRate: 44100,
+		Channels:   [][]float64{make([]float64, 1000), make([]float64, 600)},
+	}
+
+	out := &NullOutput{BufferSize: 64}
+	if err := soundio.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+
+	// The shorter channel is padded.
+	if out.Frames != 1000 {
+		t.Errorf("got %d frames, want 1000", out.Frames)
+	}
+	if out.SampleRate != 44100 {
+		t.Errorf("got sample rate %v, want 44100", out.SampleRate)
+	}
+}
@@ -0,0 +1,125 @@
+package soundio
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/music"
+	"strings"
+)
+
+// Metadata describes a recording: its tags, the tempo and key it was
+// composed in, and positions marked within it. Not every file format can hold
+// every field; the outputs document which ones they write.
+type Metadata struct {
+	Title   string
+	Artist  string
+	Comment string
+
+	// Tempo in beats per minute, or zero if unknown.
+	BPM float64
+
+	// Key of the recording, or nil if unknown. Formats that only record a
+	// root note give a Scale with no Intervals.
+	Key *music.Scale
+
+	Loops   []Loop
+	Markers []Marker
+
+	// Broadcast Wave Format extension, or nil.
+	Broadcast *Broadcast
+}
+
+// Ways of playing a Loop.
+type LoopMode int
+
+const (
+	LoopForward LoopMode = iota
+	LoopPingPong
+	LoopBackward
+)
+
+// A Loop marks the frames [Start, End) to be repeated by a sampler.
+type Loop struct {
+	Start int64
+	End   int64
+	Mode  LoopMode
+}
+
+// A Marker is a labelled position in a file.
+type Marker struct {
+	Position int64 // sample frame
+	Label    string
+}
+
+// Broadcast holds the fields of the EBU Broadcast Wave Format 'bext' chunk
+// (EBU Tech 3285). Text fields are truncated to the lengths allowed by the
+// format.
+type Broadcast struct {
+	Description         string // up to 256 characters
+	Originator          string // up to 32 characters
+	OriginatorReference string // up to 32 characters
+	OriginationDate     string // yyyy-mm-dd
+	OriginationTime     string // hh:mm:ss
+
+	// Position of the first frame, in frames since midnight.
+	TimeReference uint64
+
+	// SMPTE unique material identifier, or zero.
+	UMID [64]byte
+
+	// History of the coding processes applied, one per line.
+	CodingHistory string
+}
+
+// FormatKey returns a key in the short notation used by tags such as the
+// Vorbis comment KEY and ID3 TKEY: the root, followed by "m" for minor keys
+// (for example "C", "F#m").
+func FormatKey(key music.Scale) string {
+	s := key.Root.Letter().String()
+	if strings.HasSuffix(key.Mode(), "minor") {
+		s += "m"
+	}
+	return s
+}
+
+// Names of the letters accepted by ParseKey, including flats.
+var keyLetters = map[string]music.NoteLetter{
+	"C": music.C, "C#": music.CSharp, "Db": music.DFlat,
+	"D": music.D, "D#": music.DSharp, "Eb": music.EFlat,
+	"E": music.E,
+	"F": music.F, "F#": music.FSharp, "Gb": music.GFlat,
+	"G": music.G, "G#": music.GSharp, "Ab": music.AFlat,
+	"A": music.A, "A#": music.ASharp, "Bb": music.BFlat,
+	"B": music.B,
+}
+
+// ParseKey parses a key written as by FormatKey, or with the mode spelled out
+// (for example "Bb minor", "E major"). A key with no mode is major.
+func ParseKey(s string) (key music.Scale, err error) {
+	s = strings.TrimSpace(s)
+	root, mode := s, ""
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		root, mode = s[:i], strings.ToLower(strings.TrimSpace(s[i+1:]))
+	} else if len(s) > 1 && strings.HasSuffix(s, "m") {
+		root, mode = s[:len(s)-1], "minor"
+	}
+
+	if len(root) > 0 {
+		root = strings.ToUpper(root[:1]) + root[1:]
+	}
+	letter, ok := keyLetters[root]
+	if !ok {
+		return key, fmt.Errorf("soundio: bad key: %q", s)
+	}
+
+	key.Root = music.MakeNote(letter, 4)
+	switch mode {
+	case "", "major", "maj":
+		key.Intervals = music.Major
+	case "minor", "min":
+		key.Intervals = music.Minor
+	default:
+		return music.Scale{}, fmt.Errorf("soundio: bad key: %q", s)
+	}
+
+	return key, nil
+}
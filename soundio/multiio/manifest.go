@@ -0,0 +1,59 @@
+package multiio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A Manifest describes the files written by a multi-file output.
+type Manifest struct {
+	SampleRate float64 `json:"sample_rate"`
+	Files      []Piece `json:"files"`
+}
+
+// WriteManifest writes a JSON manifest of the files written from a render at
+// the given sample rate.
+func WriteManifest(w io.Writer, sampleRate float64, pieces []Piece) (err error) {
+	enc := json.NewEncoder(w)
+	return enc.Encode(Manifest{
+		SampleRate: sampleRate,
+		Files:      pieces,
+	})
+}
+
+// WriteCueSheet writes a cue sheet with one track per file. The files are
+// referred to by their base names, so the cue sheet should be kept in the same
+// directory.
+func WriteCueSheet(w io.Writer, pieces []Piece) (err error) {
+	if len(pieces) > 99 {
+		return fmt.Errorf("multiio: too many files for a cue sheet: %d", len(pieces))
+	}
+
+	for i, piece := range pieces {
+		name := filepath.Base(piece.Filename)
+		ext := filepath.Ext(name)
+
+		fileType := "WAVE"
+		switch strings.ToLower(ext) {
+		case ".aif", ".aiff", ".aifc":
+			fileType = "AIFF"
+		}
+
+		_, err = fmt.Fprintf(w, "FILE %s %s\n  TRACK %02d AUDIO\n    TITLE %s\n    INDEX 01 00:00:00\n",
+			quote(name), fileType, i+1, quote(strings.TrimSuffix(name, ext)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Quote a cue sheet string. The format has no escapes, so double quotes are
+// replaced with single quotes.
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, "'", -1) + `"`
+}
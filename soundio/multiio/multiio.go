@@ -0,0 +1,169 @@
+// Package multiio provides sound outputs that write a render to several files:
+// stems holding groups of channels, and segments of fixed length or separated
+// by silence. The files written can be described by a JSON manifest or a cue
+// sheet.
+package multiio
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"sync"
+)
+
+// An OutputFunc creates the output that writes one file.
+type OutputFunc func(filename string) soundio.SoundOutput
+
+// A Piece describes one file written by a multi-file output.
+type Piece struct {
+	Filename string `json:"filename"`
+
+	// Indices of the channels of the render that were written to the file.
+	Channels []int `json:"channels"`
+
+	// Position of the first frame of the file in the render.
+	Start int64 `json:"start"`
+
+	// Number of frames in the file.
+	Frames int64 `json:"frames"`
+}
+
+// A MultiOutput is a SoundOutput that writes several files.
+type MultiOutput interface {
+	soundio.SoundOutput
+
+	// Files describes the files written by the last call to Write.
+	Files() []Piece
+}
+
+// A Stem is a group of channels written to one file.
+type Stem struct {
+	Filename string
+	Channels []int
+}
+
+// EachChannel returns a stem for each of 'n' channels, named by formatting
+// 'pattern' with the channel number counting from 1.
+func EachChannel(pattern string, n int) (stems []Stem) {
+	stems = make([]Stem, n)
+	for i := range stems {
+		stems[i] = Stem{
+			Filename: fmt.Sprintf(pattern, i+1),
+			Channels: []int{i},
+		}
+	}
+	return stems
+}
+
+// Stems writes groups of channels to separate files. A channel may belong to
+// several stems, and channels that belong to none are discarded. Since Write
+// records the files written, it must be called through a pointer.
+type Stems struct {
+	Stems      []Stem
+	NewOutput  OutputFunc
+	BufferSize int
+
+	pieces []Piece
+}
+
+func (so *Stems) Write(sampleRate float64, channels []chan float64) (err error) {
+	so.pieces = nil
+
+	for _, stem := range so.Stems {
+		for _, c := range stem.Channels {
+			if c < 0 || c >= len(channels) {
+				return fmt.Errorf("multiio: stem %s uses channel %d of %d", stem.Filename, c, len(channels))
+			}
+		}
+	}
+
+	// Copy each channel to the inputs of the stems that use it.
+	inputs := make([][]chan float64, len(so.Stems))
+	consumers := make([][]chan float64, len(channels))
+	for i, stem := range so.Stems {
+		for _, c := range stem.Channels {
+			input := make(chan float64, so.BufferSize)
+			inputs[i] = append(inputs[i], input)
+			consumers[c] = append(consumers[c], input)
+		}
+	}
+
+	frames := make([]int64, len(channels))
+	var copyWG sync.WaitGroup
+	for c, channel := range channels {
+		copyWG.Add(1)
+		go func(c int, channel chan float64) {
+			for x := range channel {
+				frames[c]++
+				for _, input := range consumers[c] {
+					input <- x
+				}
+			}
+			for _, input := range consumers[c] {
+				close(input)
+			}
+			copyWG.Done()
+		}(c, channel)
+	}
+
+	// Every stem must be written concurrently, since they share channels.
+	outputs := make([]soundio.SoundOutput, len(so.Stems))
+	errs := make([]error, len(so.Stems))
+	var writeWG sync.WaitGroup
+	for i, stem := range so.Stems {
+		outputs[i] = so.NewOutput(stem.Filename)
+		writeWG.Add(1)
+		go func(i int) {
+			errs[i] = outputs[i].Write(sampleRate, inputs[i])
+
+			// Keep the other stems going if this one stopped early.
+			for _, input := range inputs[i] {
+				go drain(input)
+			}
+			writeWG.Done()
+		}(i)
+	}
+	writeWG.Wait()
+	copyWG.Wait()
+
+	for i, stem := range so.Stems {
+		if errs[i] != nil {
+			return errs[i]
+		}
+
+		if multi, ok := outputs[i].(MultiOutput); ok {
+			for _, piece := range multi.Files() {
+				mapped := make([]int, len(piece.Channels))
+				for j, c := range piece.Channels {
+					mapped[j] = stem.Channels[c]
+				}
+				piece.Channels = mapped
+				so.pieces = append(so.pieces, piece)
+			}
+			continue
+		}
+
+		var n int64
+		for _, c := range stem.Channels {
+			if frames[c] > n {
+				n = frames[c]
+			}
+		}
+		so.pieces = append(so.pieces, Piece{
+			Filename: stem.Filename,
+			Channels: stem.Channels,
+			Frames:   n,
+		})
+	}
+
+	return nil
+}
+
+// Files describes the files written by the last call to Write.
+func (so *Stems) Files() []Piece {
+	return so.pieces
+}
+
+func drain(channel chan float64) {
+	for _ = range channel {
+	}
+}
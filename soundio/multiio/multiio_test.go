@@ -0,0 +1,181 @@
+package multiio
+
+import (
+	"errors"
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Outputs created by an OutputFunc, keyed by filename.
+type recorder struct {
+	mutex   sync.Mutex
+	outputs map[string]*memio.Output
+}
+
+func (r *recorder) newOutput(filename string) soundio.SoundOutput {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.outputs == nil {
+		r.outputs = make(map[string]*memio.Output)
+	}
+	so := new(memio.Output)
+	r.outputs[filename] = so
+	return so
+}
+
+func ramp(start, n int) (buffer []float64) {
+	buffer = make([]float64, n)
+	for i := range buffer {
+		buffer[i] = float64(start+i) / 1000
+	}
+	return buffer
+}
+
+func checkBuffer(t *testing.T, name string, got, want []float64) {
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d samples, want %d", name, len(got), len(want))
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: sample %d is %v, want %v", name, i, got[i], want[i])
+			return
+		}
+	}
+}
+
+func TestStems(t *testing.T) {
+	r := new(recorder)
+	in := memio.Input{
+		SampleRate: 44100,
+		Channels:   [][]float64{ramp(0, 100), ramp(100, 100), ramp(200, 100)},
+		BufferSize: 8,
+	}
+
+	so := &Stems{
+		Stems:      []Stem{{"a", []int{0, 2}}, {"b", []int{1}}},
+		NewOutput:  r.newOutput,
+		BufferSize: 8,
+	}
+	if err := soundio.Copy(so, in); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := r.outputs["a"], r.outputs["b"]
+	if a == nil || b == nil || len(a.Channels) != 2 || len(b.Channels) != 1 {
+		t.Fatalf("got outputs %v", r.outputs)
+	}
+	checkBuffer(t, "a/0", a.Channels[0], in.Channels[0])
+	checkBuffer(t, "a/1", a.Channels[1], in.Channels[2])
+	checkBuffer(t, "b/0", b.Channels[0], in.Channels[1])
+
+	if pieces := so.Files(); len(pieces) != 2 || pieces[0].Frames != 100 {
+		t.Errorf("got pieces %v", pieces)
+	}
+}
+
+func TestSplitterLength(t *testing.T) {
+	r := new(recorder)
+	in := memio.Input{
+		SampleRate: 1000,
+		Channels:   [][]float64{ramp(1, 250)},
+		BufferSize: 8,
+	}
+
+	so := &Splitter{
+		Pattern:    "part-%d",
+		NewOutput:  r.newOutput,
+		BufferSize: 8,
+		Length:     time.Millisecond * 100,
+	}
+	if err := soundio.Copy(so, in); err != nil {
+		t.Fatal(err)
+	}
+
+	pieces := so.Files()
+	if len(pieces) != 3 {
+		t.Fatalf("got pieces %v", pieces)
+	}
+	for i, piece := range pieces {
+		out := r.outputs[piece.Filename]
+		if out == nil || len(out.Channels) != 1 {
+			t.Fatalf("no output for %v", piece)
+		}
+		want := in.Channels[0][piece.Start : piece.Start+piece.Frames]
+		checkBuffer(t, piece.Filename, out.Channels[0], want)
+		if piece.Start != int64(i*100) {
+			t.Errorf("piece %d starts at %d, want %d", i, piece.Start, i*100)
+		}
+	}
+}
+
+func TestSplitterSilence(t *testing.T) {
+	r := new(recorder)
+	buffer := append(append(append(make([]float64, 50), ramp(1, 100)...), make([]float64, 300)...), ramp(1, 100)...)
+	in := memio.Input{
+		SampleRate: 1000,
+		Channels:   [][]float64{buffer},
+		BufferSize: 8,
+	}
+
+	so := &Splitter{
+		Pattern:    "take-%d",
+		NewOutput:  r.newOutput,
+		BufferSize: 8,
+		MinSilence: time.Millisecond * 200,
+		Threshold:  -60,
+	}
+	if err := soundio.Copy(so, in); err != nil {
+		t.Fatal(err)
+	}
+
+	pieces := so.Files()
+	if len(pieces) != 2 {
+		t.Fatalf("got pieces %v", pieces)
+	}
+	for _, piece := range pieces {
+		checkBuffer(t, piece.Filename, r.outputs[piece.Filename].Channels[0], ramp(1, 100))
+	}
+}
+
+// An output that fails without reading its channels.
+type failingOutput struct{}
+
+var errFailed = errors.New("failed")
+
+func (failingOutput) Write(sampleRate float64, channels []chan float64) error {
+	return errFailed
+}
+
+// A failed file must not leave the producers of the render blocked.
+func TestSplitterError(t *testing.T) {
+	input := make(chan float64)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 10000; i++ {
+			input <- 0.5
+		}
+		close(input)
+		done <- true
+	}()
+
+	so := &Splitter{
+		Pattern:    "part-%d",
+		NewOutput:  func(string) soundio.SoundOutput { return failingOutput{} },
+		BufferSize: 8,
+		Length:     time.Millisecond * 100,
+	}
+	if err := so.Write(1000, []chan float64{input}); err != errFailed {
+		t.Errorf("got error %v, want %v", err, errFailed)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("input not drained after an error")
+	}
+}
@@ -0,0 +1,214 @@
+package multiio
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"math"
+	"time"
+)
+
+// Splitter writes a render to a sequence of files, starting a new file when
+// the current one reaches Length or when the render falls silent for at least
+// MinSilence. Since Write records the files written, it must be called
+// through a pointer.
+type Splitter struct {
+	// Filenames are made by formatting Pattern with the file number, counting
+	// from 1 (for example "take-%03d.wav").
+	Pattern    string
+	NewOutput  OutputFunc
+	BufferSize int
+
+	// Maximum length of each file. If zero, files are not split by length.
+	Length time.Duration
+
+	// Minimum length of a gap between files. If zero, files are not split by
+	// silence. Silence at the start and end of the render, and gaps of at
+	// least this length, are left out of the files.
+	MinSilence time.Duration
+
+	// Level in dBFS below which every channel must be for a frame to count as
+	// silence.
+	Threshold float64
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	pieces []Piece
+}
+
+// A file being written by a Splitter.
+type segment struct {
+	piece    Piece
+	channels []chan float64
+	result   chan error
+}
+
+func (so *Splitter) Write(sampleRate float64, channels []chan float64) (err error) {
+	so.pieces = nil
+	n := len(channels)
+
+	var maxFrames, minSilence int64
+	if so.Length > 0 {
+		maxFrames = soundio.FrameAt(so.Length, sampleRate)
+		if maxFrames < 1 {
+			maxFrames = 1
+		}
+	}
+	if so.MinSilence > 0 {
+		minSilence = soundio.FrameAt(so.MinSilence, sampleRate)
+		if minSilence < 1 {
+			minSilence = 1
+		}
+	}
+	threshold := math.Pow(10, so.Threshold/20)
+
+	var seg *segment
+	var pos int64
+
+	// On error, finish the current file and let the producers run to the
+	// end, as Stems does.
+	defer func() {
+		if err == nil {
+			return
+		}
+		if seg != nil {
+			so.finish(seg)
+		}
+		for _, channel := range channels {
+			go drain(channel)
+		}
+	}()
+
+	// Write a frame at position 'at' in the render to the current file,
+	// starting a new one if needed.
+	writeFrame := func(frame []float64, at int64) (err error) {
+		if seg != nil && maxFrames > 0 && seg.piece.Frames == maxFrames {
+			err = so.finish(seg)
+			seg = nil
+			if err != nil {
+				return err
+			}
+		}
+		if seg == nil {
+			seg = so.start(sampleRate, n, at)
+		}
+
+		for c, x := range frame {
+			seg.channels[c] <- x
+		}
+		seg.piece.Frames++
+		return nil
+	}
+
+	// Silent frames held back until it is known whether the gap is long
+	// enough to end the file.
+	var pending []float64
+
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		for i := 0; i < len(buffer); i += n {
+			frame := buffer[i : i+n]
+
+			if minSilence > 0 && silent(frame, threshold) {
+				gap := int64(len(pending) / n)
+				if gap < minSilence {
+					pending = append(pending, frame...)
+					gap++
+				}
+				if gap == minSilence && seg != nil {
+					err = so.finish(seg)
+					seg = nil
+					if err != nil {
+						return err
+					}
+				}
+				pos++
+				continue
+			}
+
+			// A short gap within a file is kept; a long one has already
+			// ended the file and is dropped.
+			if seg != nil {
+				at := pos - int64(len(pending)/n)
+				for j := 0; j < len(pending); j += n {
+					err = writeFrame(pending[j:j+n], at)
+					if err != nil {
+						return err
+					}
+					at++
+				}
+			}
+			pending = pending[:0]
+
+			err = writeFrame(frame, pos)
+			if err != nil {
+				return err
+			}
+			pos++
+		}
+		il.Release(buffer)
+	}
+
+	if seg != nil {
+		err = so.finish(seg)
+		seg = nil
+		if err != nil {
+			return err
+		}
+	}
+	return il.Err()
+}
+
+// Start writing a new file whose first frame is at position 'at' in the
+// render.
+func (so *Splitter) start(sampleRate float64, n int, at int64) (seg *segment) {
+	seg = &segment{
+		channels: make([]chan float64, n),
+		result:   make(chan error, 1),
+	}
+	seg.piece.Filename = fmt.Sprintf(so.Pattern, len(so.pieces)+1)
+	seg.piece.Start = at
+	seg.piece.Channels = make([]int, n)
+	for c := range seg.channels {
+		seg.channels[c] = make(chan float64, so.BufferSize)
+		seg.piece.Channels[c] = c
+	}
+
+	so.pieces = append(so.pieces, seg.piece)
+	output := so.NewOutput(seg.piece.Filename)
+	go func() {
+		err := output.Write(sampleRate, seg.channels)
+		for _, channel := range seg.channels {
+			go drain(channel)
+		}
+		seg.result <- err
+	}()
+
+	return seg
+}
+
+// Close the channels of a file and wait for it to be written.
+func (so *Splitter) finish(seg *segment) (err error) {
+	for _, channel := range seg.channels {
+		close(channel)
+	}
+	so.pieces[len(so.pieces)-1] = seg.piece
+	return <-seg.result
+}
+
+// Files describes the files written by the last call to Write.
+func (so *Splitter) Files() []Piece {
+	return so.pieces
+}
+
+// Return whether every sample of a frame is below 'threshold' in magnitude.
+func silent(frame []float64, threshold float64) bool {
+	for _, x := range frame {
+		if math.Abs(x) >= threshold {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package soundio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// A SampleFormat is a binary encoding of individual samples, as used by PCM
+// file formats and audio devices. The zero value is Int16, so outputs whose
+// Format is not set write 16-bit samples.
+type SampleFormat int
+
+const (
+	Int16 SampleFormat = iota
+	Int8
+	Uint8
+	Int24
+	Int32
+	Float32
+	Float64
+)
+
+// Size returns the number of bytes occupied by one sample.
+func (f SampleFormat) Size() int {
+	switch f {
+	case Int8, Uint8:
+		return 1
+	case Int16:
+		return 2
+	case Int24:
+		return 3
+	case Int32, Float32:
+		return 4
+	case Float64:
+		return 8
+	}
+	return 0
+}
+
+// Bits returns the number of bits in one sample.
+func (f SampleFormat) Bits() int {
+	return f.Size() * 8
+}
+
+// IsFloat returns whether the format stores IEEE floating point values.
+func (f SampleFormat) IsFloat() bool {
+	return f == Float32 || f == Float64
+}
+
+func (f SampleFormat) String() string {
+	switch f {
+	case Int8:
+		return "int8"
+	case Uint8:
+		return "uint8"
+	case Int16:
+		return "int16"
+	case Int24:
+		return "int24"
+	case Int32:
+		return "int32"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	}
+	return "unknown"
+}
+
+// Full-scale value of an integer format, i.e. 2^(bits-1).
+func (f SampleFormat) scale() float64 {
+	return float64(uint64(1) << uint(f.Bits()-1))
+}
+
+// Encode writes 'x' to the start of 'dst' in this format. Values outside
+// [-1, 1) are clipped to the largest representable value rather than wrapping
+// around.
+func (f SampleFormat) Encode(dst []byte, x float64, order binary.ByteOrder) {
+	switch f {
+	case Float32:
+		order.PutUint32(dst, math.Float32bits(float32(x)))
+		return
+	case Float64:
+		order.PutUint64(dst, math.Float64bits(x))
+		return
+	}
+
+	scale := f.scale()
+	f.putInt(dst, round(x*scale, scale), order)
+}
+
+// Round a value in units of the least significant bit to the nearest integer,
+// saturating at the limits of a format whose full-scale value is 'scale'.
+func round(y float64, scale float64) int32 {
+	y = math.Floor(y + 0.5)
+	if y > scale-1 {
+		y = scale - 1
+	} else if y < -scale {
+		y = -scale
+	}
+	return int32(y)
+}
+
+// Write an integer sample of an integer format.
+func (f SampleFormat) putInt(dst []byte, v int32, order binary.ByteOrder) {
+	switch f {
+	case Int8:
+		dst[0] = uint8(v)
+	case Uint8:
+		dst[0] = uint8(v + 128)
+	case Int16:
+		order.PutUint16(dst, uint16(v))
+	case Int24:
+		if order == binary.BigEndian {
+			dst[0], dst[1], dst[2] = uint8(v>>16), uint8(v>>8), uint8(v)
+		} else {
+			dst[0], dst[1], dst[2] = uint8(v), uint8(v>>8), uint8(v>>16)
+		}
+	case Int32:
+		order.PutUint32(dst, uint32(v))
+	}
+}
+
+// Decode reads a sample in this format from the start of 'src', scaling
+// integer formats to [-1, 1).
+func (f SampleFormat) Decode(src []byte, order binary.ByteOrder) (x float64) {
+	var v int32
+
+	switch f {
+	case Float32:
+		return float64(math.Float32frombits(order.Uint32(src)))
+	case Float64:
+		return math.Float64frombits(order.Uint64(src))
+	case Int8:
+		v = int32(int8(src[0]))
+	case Uint8:
+		v = int32(src[0]) - 128
+	case Int16:
+		v = int32(int16(order.Uint16(src)))
+	case Int24:
+		if order == binary.BigEndian {
+			v = int32(src[0])<<16 | int32(src[1])<<8 | int32(src[2])
+		} else {
+			v = int32(src[2])<<16 | int32(src[1])<<8 | int32(src[0])
+		}
+		// Sign-extend
+		v = v << 8 >> 8
+	case Int32:
+		v = int32(order.Uint32(src))
+	}
+
+	return float64(v) / f.scale()
+}
@@ -0,0 +1,130 @@
+// Package rawio reads and writes headerless interleaved PCM over an
+// io.Reader or io.Writer, such as standard input and output.
+package rawio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+)
+
+// ErrNoChannels is returned by Input.Read when Channels is not positive.
+var ErrNoChannels = errors.New("rawio: no channels")
+
+type Input struct {
+	Reader     io.Reader
+	Format     soundio.SampleFormat
+	ByteOrder  binary.ByteOrder
+	SampleRate float64
+	Channels   int
+	BufferSize int
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	if si.Channels <= 0 {
+		errChan <- ErrNoChannels
+		close(errChan)
+		return si.SampleRate, nil, errChan
+	}
+	if si.BufferSize <= 0 {
+		si.BufferSize = soundio.DefaultBufferSize
+	}
+
+	channels = make([]chan float64, si.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		sampleSize := si.Format.Size()
+		frameSize := sampleSize * si.Channels
+		byteBuffer := make([]byte, frameSize*si.BufferSize)
+		buffer := make([]float64, si.Channels*si.BufferSize)
+		r := bufio.NewReader(si.Reader)
+
+		for {
+			n, err := io.ReadFull(r, byteBuffer)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				errChan <- err
+				return
+			}
+
+			// Discard any partial frame at the end.
+			n -= n % frameSize
+			for i := 0; i < n/sampleSize; i++ {
+				buffer[i] = si.Format.Decode(byteBuffer[i*sampleSize:], si.ByteOrder)
+			}
+			soundio.Deinterlace(buffer[:n/sampleSize], channels)
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return si.SampleRate, channels, errChan
+}
+
+type Output struct {
+	// Destination of the samples. If Filename is given instead, that file is
+	// created and closed when writing finishes.
+	Writer   io.Writer
+	Filename string
+
+	Format     soundio.SampleFormat
+	ByteOrder  binary.ByteOrder
+	BufferSize int
+
+	// Dither applied when converting to an integer format.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+}
+
+func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	if so.Filename != "" {
+		f, err := os.Create(so.Filename)
+		if err != nil {
+			return err
+		}
+
+		so.Writer, so.Filename = f, ""
+		err = so.Write(sampleRate, channels)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	sampleSize := so.Format.Size()
+	q := soundio.NewQuantizer(so.Format, so.Dither, len(channels))
+
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	byteBuffer := make([]byte, len(channels)*il.BufferSize*sampleSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		q.Encode(byteBuffer, buffer, so.ByteOrder)
+
+		_, err = so.Writer.Write(byteBuffer[:len(buffer)*sampleSize])
+		if err != nil {
+			return err
+		}
+		il.Release(buffer)
+	}
+
+	return il.Err()
+}
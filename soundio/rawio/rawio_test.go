@@ -0,0 +1,142 @@
+package rawio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func stream(values ...float64) chan float64 {
+	c := make(chan float64, len(values))
+	for _, x := range values {
+		c <- x
+	}
+	close(c)
+	return c
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		buf := new(bytes.Buffer)
+		so := Output{Writer: buf, Format: soundio.Int16, ByteOrder: order, BufferSize: 2}
+		err := so.Write(44100, []chan float64{stream(0, 0.5, -0.5), stream(0.25, -0.25, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		si := Input{Reader: buf, Format: soundio.Int16, ByteOrder: order, SampleRate: 44100, Channels: 2, BufferSize: 2}
+		_, channels, errChan := si.Read()
+		var got [2][]float64
+		done := make(chan bool)
+		go func() {
+			for x := range channels[1] {
+				got[1] = append(got[1], x)
+			}
+			done <- true
+		}()
+		for x := range channels[0] {
+			got[0] = append(got[0], x)
+		}
+		<-done
+		if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+
+		want := [2][]float64{{0, 0.5, -0.5}, {0.25, -0.25, 32767.0 / 32768}}
+		for c := range want {
+			if len(got[c]) != len(want[c]) {
+				t.Fatalf("%v channel %d: got %v, want %v", order, c, got[c], want[c])
+			}
+			for i := range want[c] {
+				if got[c][i] != want[c][i] {
+					t.Errorf("%v channel %d: got %v, want %v", order, c, got[c], want[c])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestOutputFilename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "out.raw")
+	so := Output{Filename: filename, Format: soundio.Int16, ByteOrder: binary.BigEndian, BufferSize: 4}
+	if err := so.Write(44100, []chan float64{stream(0.5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x40, 0x00}) {
+		t.Errorf("got % x, want 40 00", data)
+	}
+}
+
+func TestInputNoChannels(t *testing.T) {
+	si := Input{Reader: bytes.NewReader(make([]byte, 16)), Format: soundio.Int16, ByteOrder: binary.LittleEndian}
+	_, channels, errChan := si.Read()
+	if len(channels) != 0 {
+		t.Errorf("got %d channels", len(channels))
+	}
+	if err := <-errChan; err != ErrNoChannels {
+		t.Errorf("got error %v, want ErrNoChannels", err)
+	}
+}
+
+func TestOutputLengthPolicy(t *testing.T) {
+	so := Output{Writer: new(bytes.Buffer), Format: soundio.Int16, ByteOrder: binary.LittleEndian, BufferSize: 2}
+
+	so.LengthPolicy = soundio.RequireEqualLength
+	err := so.Write(44100, []chan float64{stream(1, 2, 3), stream(1, 2)})
+	if err != soundio.ErrLengthMismatch {
+		t.Errorf("got error %v, want ErrLengthMismatch", err)
+	}
+
+	buf := new(bytes.Buffer)
+	so.Writer = buf
+	so.LengthPolicy = soundio.TruncateToShortest
+	err = so.Write(44100, []chan float64{stream(0, 0, 0), stream(0, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 2*2*2 {
+		t.Errorf("wrote %d bytes, want 8", buf.Len())
+	}
+}
+
+// Reading with a zero buffer size must still deliver every frame.
+func TestInputZeroBufferSize(t *testing.T) {
+	data := make([]byte, 2*2*1001)
+	si := Input{Reader: bytes.NewReader(data), Format: soundio.Int16, ByteOrder: binary.LittleEndian, SampleRate: 44100, Channels: 2}
+
+	out := new(memio.Output)
+	done := make(chan error)
+	go func() {
+		done <- soundio.Copy(out, si)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reading with a zero buffer size did not finish")
+	}
+	if len(out.Channels) != 2 || len(out.Channels[0]) != 1001 || len(out.Channels[1]) != 1001 {
+		t.Errorf("got channels of lengths %d and %d, want 1001", len(out.Channels[0]), len(out.Channels[1]))
+	}
+}
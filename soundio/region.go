@@ -0,0 +1,89 @@
+package soundio
+
+import (
+	"math"
+	"time"
+)
+
+// FileInfo describes the audio in a file, as reported by an input before it
+// starts streaming.
+type FileInfo struct {
+	SampleRate float64
+	Channels   int
+
+	// Number of frames in the file, or -1 if unknown.
+	Frames int64
+
+	// Description of the container and sample format, such as "WAV int16".
+	Format string
+}
+
+// Duration returns the length of the file, or -1 if unknown.
+func (fi FileInfo) Duration() time.Duration {
+	if fi.Frames < 0 {
+		return -1
+	}
+	return time.Duration(float64(fi.Frames) / fi.SampleRate * float64(time.Second))
+}
+
+// FrameAt returns the index of the frame at time 't' from the start of the
+// file.
+func (fi FileInfo) FrameAt(t time.Duration) int64 {
+	return FrameAt(t, fi.SampleRate)
+}
+
+// FrameAt returns the index of the frame at time 't' from the start of a
+// stream with the given sample rate.
+func FrameAt(t time.Duration, sampleRate float64) int64 {
+	return int64(math.Floor(t.Seconds()*sampleRate + 0.5))
+}
+
+// Play a region indefinitely.
+const LoopForever = -1
+
+// A Region selects the frames [Start, End) of a file to read, and how many
+// times to play them.
+type Region struct {
+	Start int64
+
+	// If zero, the region extends to the end of the file.
+	End int64
+
+	// Number of times to play the region, or LoopForever. Zero means once.
+	Loops int
+}
+
+// Bounds returns the first and one-past-last frames of the region in a file of
+// the given number of frames, clamped to the file. If 'frames' is -1 (unknown)
+// and the region extends to the end of the file, 'end' is -1.
+func (r Region) Bounds(frames int64) (start, end int64) {
+	start, end = r.Start, r.End
+	if start < 0 {
+		start = 0
+	}
+	if frames >= 0 && start > frames {
+		start = frames
+	}
+
+	if end <= 0 || (frames >= 0 && end > frames) {
+		end = frames
+	}
+	if end >= 0 && end < start {
+		end = start
+	}
+	return start, end
+}
+
+// Repeat calls 'pass' once for each time the region is to be played, stopping
+// at the first error. 'pass' returns the number of frames it played; a pass
+// that plays none also stops the repetition, since the region is empty and
+// looping it forever would never end.
+func (r Region) Repeat(pass func() (frames int64, err error)) (err error) {
+	for i := 0; r.Loops == LoopForever || i < r.Loops || i == 0; i++ {
+		frames, err := pass()
+		if err != nil || frames == 0 {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package soundio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegionRepeat(t *testing.T) {
+	passes := 0
+	err := Region{Loops: 3}.Repeat(func() (int64, error) {
+		passes++
+		return 10, nil
+	})
+	if err != nil || passes != 3 {
+		t.Errorf("got %d passes and error %v, want 3 and nil", passes, err)
+	}
+
+	passes = 0
+	failure := errors.New("failure")
+	err = Region{Loops: LoopForever}.Repeat(func() (int64, error) {
+		passes++
+		return 10, failure
+	})
+	if err != failure || passes != 1 {
+		t.Errorf("got %d passes and error %v, want 1 and failure", passes, err)
+	}
+}
+
+// An empty region must not be looped forever.
+func TestRegionRepeatEmpty(t *testing.T) {
+	passes := 0
+	err := Region{Loops: LoopForever}.Repeat(func() (int64, error) {
+		passes++
+		if passes > 2 {
+			return 0, nil
+		}
+		return 10, nil
+	})
+	if err != nil || passes != 3 {
+		t.Errorf("got %d passes and error %v, want 3 and nil", passes, err)
+	}
+}
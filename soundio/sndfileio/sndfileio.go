@@ -1,6 +1,7 @@
 package sndfileio
 
 import (
+	"fmt"
 	"github.com/kierdavis/gosound/soundio"
 	"github.com/mkb218/gosndfile/sndfile"
 )
@@ -8,9 +9,67 @@ import (
 type SndFileInput struct {
 	Filename   string
 	BufferSize int
+
+	// Part of the file to read. The zero Region reads the whole file once.
+	Region soundio.Region
+}
+
+// Names of the major formats, used by Info.
+var formatNames = map[sndfile.Format]string{
+	sndfile.SF_FORMAT_WAV:  "WAV",
+	sndfile.SF_FORMAT_AIFF: "AIFF",
+	sndfile.SF_FORMAT_AU:   "AU",
+	sndfile.SF_FORMAT_RAW:  "raw",
+	sndfile.SF_FORMAT_FLAC: "FLAC",
+	sndfile.SF_FORMAT_OGG:  "Ogg",
+}
+
+// Names of the sample formats, used by Info.
+var subtypeNames = map[sndfile.Format]string{
+	sndfile.SF_FORMAT_PCM_S8: "int8",
+	sndfile.SF_FORMAT_PCM_U8: "uint8",
+	sndfile.SF_FORMAT_PCM_16: "int16",
+	sndfile.SF_FORMAT_PCM_24: "int24",
+	sndfile.SF_FORMAT_PCM_32: "int32",
+	sndfile.SF_FORMAT_FLOAT:  "float32",
+	sndfile.SF_FORMAT_DOUBLE: "float64",
+	sndfile.SF_FORMAT_VORBIS: "Vorbis",
+}
+
+// Info reads the format and length of the file without streaming it.
+func (si SndFileInput) Info() (fi soundio.FileInfo, err error) {
+	var info sndfile.Info
+	f, err := sndfile.Open(si.Filename, sndfile.Read, &info)
+	if err != nil {
+		return fi, err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fi, err
+	}
+
+	major, ok := formatNames[info.Format&sndfile.SF_FORMAT_TYPEMASK]
+	if !ok {
+		major = fmt.Sprintf("format 0x%x", int32(info.Format&sndfile.SF_FORMAT_TYPEMASK))
+	}
+	subtype, ok := subtypeNames[info.Format&sndfile.SF_FORMAT_SUBMASK]
+	if !ok {
+		subtype = fmt.Sprintf("subtype 0x%x", int32(info.Format&sndfile.SF_FORMAT_SUBMASK))
+	}
+
+	return soundio.FileInfo{
+		SampleRate: float64(info.Samplerate),
+		Channels:   int(info.Channels),
+		Frames:     info.Frames,
+		Format:     major + " " + subtype,
+	}, nil
 }
 
 func (si SndFileInput) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	if si.BufferSize <= 0 {
+		si.BufferSize = soundio.DefaultBufferSize
+	}
 	errChan = make(chan error, 2)
 
 	var info sndfile.Info
@@ -38,23 +97,49 @@ func (si SndFileInput) Read() (sampleRate float64, channels []chan float64, errC
 			}
 		}()
 
+		start, end := si.Region.Bounds(info.Frames)
+		if start == end {
+			return
+		}
+
 		buffer := make([]float64, len(channels)*si.BufferSize)
 
-		for {
-			numItems, err := f.ReadItems(buffer)
+		err := si.Region.Repeat(func() (frames int64, err error) {
+			_, err = f.Seek(start, sndfile.Set)
 			if err != nil {
-				errChan <- err
-				return
+				return 0, err
 			}
 
-			// EOF
-			if numItems == 0 {
-				break
-			}
+			// Number of items left in the region
+			remaining := (end - start) * int64(len(channels))
+
+			for remaining != 0 {
+				items := buffer
+				if remaining > 0 && remaining < int64(len(items)) {
+					items = items[:remaining]
+				}
 
-			for i, x := range buffer[:numItems] {
-				channels[i%len(channels)] <- x
+				numItems, err := f.ReadItems(items)
+				if err != nil {
+					return frames, err
+				}
+
+				// EOF
+				if numItems == 0 {
+					break
+				}
+
+				soundio.Deinterlace(items[:numItems], channels)
+				frames += numItems / int64(len(channels))
+				if remaining > 0 {
+					remaining -= numItems
+				}
 			}
+
+			return frames, nil
+		})
+		if err != nil {
+			errChan <- err
 		}
 	}()
 
@@ -70,6 +155,9 @@ type SndFileInputRAW struct {
 }
 
 func (si SndFileInputRAW) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	if si.BufferSize <= 0 {
+		si.BufferSize = soundio.DefaultBufferSize
+	}
 	errChan = make(chan error, 2)
 
 	info := sndfile.Info{
@@ -116,9 +204,7 @@ func (si SndFileInputRAW) Read() (sampleRate float64, channels []chan float64, e
 				break
 			}
 
-			for i, x := range buffer[:numItems] {
-				channels[i%len(channels)] <- x
-			}
+			soundio.Deinterlace(buffer[:numItems], channels)
 		}
 	}()
 
@@ -129,6 +215,23 @@ type SndFileOutput struct {
 	Filename   string
 	Format     sndfile.Format
 	BufferSize int
+
+	// Dither applied when writing an integer PCM subtype.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+}
+
+// Sample sizes of the integer PCM subtypes.
+var pcmBits = map[sndfile.Format]int{
+	sndfile.SF_FORMAT_PCM_S8: 8,
+	sndfile.SF_FORMAT_PCM_U8: 8,
+	sndfile.SF_FORMAT_PCM_16: 16,
+	sndfile.SF_FORMAT_PCM_24: 24,
+	sndfile.SF_FORMAT_PCM_32: 32,
 }
 
 func (so SndFileOutput) Write(sampleRate float64, channels []chan float64) (err error) {
@@ -144,12 +247,37 @@ func (so SndFileOutput) Write(sampleRate float64, channels []chan float64) (err
 	}
 	defer f.Close()
 
-	for buffer := range soundio.Interlace(channels, so.BufferSize) {
-		_, err = f.WriteItems(buffer)
+	bits, ok := pcmBits[so.Format&sndfile.SF_FORMAT_SUBMASK]
+	if !ok {
+		// Float and compressed subtypes are converted by libsndfile.
+		il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+		for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+			_, err = f.WriteItems(buffer)
+			if err != nil {
+				return err
+			}
+			il.Release(buffer)
+		}
+		return il.Err()
+	}
+
+	// Quantize here and write left-justified 32-bit integers, which
+	// libsndfile narrows to the subtype without further rounding.
+	q := soundio.NewQuantizer(soundio.Int32, so.Dither, len(channels))
+	q.Bits = bits
+	il := soundio.NewInterlacer(channels, so.BufferSize, so.LengthPolicy)
+	items := make([]int32, len(channels)*il.BufferSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		for i, x := range buffer {
+			items[i] = q.Quantize(x, i%len(channels)) << uint(32-bits)
+		}
+
+		_, err = f.WriteItems(items[:len(buffer)])
 		if err != nil {
 			return err
 		}
+		il.Release(buffer)
 	}
 
-	return nil
+	return il.Err()
 }
@@ -10,31 +10,25 @@ type SoundOutput interface {
 	Write(float64, []chan float64) error
 }
 
-func Interlace(channels []chan float64, bufferSize int) (bufferChan chan []float64) {
-	bufferChan = make(chan []float64)
-	
-	go func() {
-		defer close(bufferChan)
-		
-		var channelsClosed uint
-		channelsClosedMax := (uint(1) << uint(len(channels))) - 1
-		
-		for channelsClosed != channelsClosedMax {
-			buffer := make([]float64, len(channels)*bufferSize)
-			
-			for i, _ := range buffer {
-				chNum := i % len(channels)
-				x, ok := <-channels[chNum]
-				if !ok {
-					channelsClosed |= 1 << uint(chNum)
-				}
+// Copy reads everything from 'si' and writes it to 'so', returning the first
+// error reported by either.
+func Copy(so SoundOutput, si SoundInput) (err error) {
+	sampleRate, channels, errChan := si.Read()
 
-				buffer[i] = x
-			}
-			
-			bufferChan <- buffer
+	err = so.Write(sampleRate, channels)
+	if err != nil {
+		// Let the input finish, so that it reports its own errors and exits.
+		for _, channel := range channels {
+			go func(channel chan float64) {
+				for _ = range channel {
+				}
+			}(channel)
 		}
-	}()
-	
-	return bufferChan
+	}
+
+	// Only the first error from the input is reported.
+	if e := <-errChan; err == nil {
+		err = e
+	}
+	return err
 }
@@ -0,0 +1,323 @@
+package wavio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+)
+
+const (
+	formatPCM        = 0x0001
+	formatFloat      = 0x0003
+	formatExtensible = 0xFFFE
+
+	// Chunk sizes of this value in an RF64 file are given by the ds64 chunk.
+	rf64Size = 0xFFFFFFFF
+)
+
+// A Cue is a marked position in a file, with an optional label.
+type Cue struct {
+	ID       uint32
+	Position uint32 // sample frame
+	Label    string
+}
+
+// Header describes the contents of a WAV file.
+type Header struct {
+	SampleRate  float64
+	Channels    int
+	Format      soundio.SampleFormat
+	ChannelMask uint32
+
+	// Number of sample frames in the file.
+	Frames int64
+
+	// Tags from the LIST/INFO chunk, keyed by chunk ID (such as "INAM" for
+	// the title or "ICMT" for comments).
+	Info map[string]string
+
+	// Cue points, labelled from the LIST/adtl chunk.
+	Cues []Cue
+
+	// Metadata gathered from the INFO tags, cue points, the smpl, acid and
+	// bext chunks and the gosound-private key chunk.
+	Metadata soundio.Metadata
+
+	// Offset of the sample data from the start of the file.
+	dataOffset int64
+	dataSize   int64
+}
+
+// BadFileError is returned when a file is not a WAV file that can be read.
+type BadFileError struct {
+	Reason string
+}
+
+func (err BadFileError) Error() string {
+	return fmt.Sprintf("wavio: %s", err.Reason)
+}
+
+// ReadHeader reads the format and metadata of a WAV file without reading its
+// sample data.
+func ReadHeader(filename string) (h Header, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+
+	return readHeader(f, true)
+}
+
+// Parse the chunks of a RIFF WAVE file. If 'seekable' is true, chunks after
+// the sample data are read too and 'r' is left at an undefined position;
+// otherwise, parsing stops at the sample data and 'r' is left at its start.
+func readHeader(r io.Reader, seekable bool) (h Header, err error) {
+	var riff [12]byte
+	_, err = io.ReadFull(r, riff[:])
+	if err != nil {
+		return h, err
+	}
+
+	isRF64 := false
+	switch string(riff[0:4]) {
+	case "RIFF":
+	case "RF64":
+		isRF64 = true
+	default:
+		return h, BadFileError{"not a RIFF file"}
+	}
+	if string(riff[8:12]) != "WAVE" {
+		return h, BadFileError{"not a WAVE file"}
+	}
+
+	var ds64DataSize int64 = -1
+	foundFormat := false
+	pos := int64(12)
+	labels := make(map[uint32]string)
+	var key []byte
+
+	for {
+		var chunkHeader [8]byte
+		_, err = io.ReadFull(r, chunkHeader[:])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return h, err
+		}
+		pos += 8
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		if id == "data" {
+			if !foundFormat {
+				return h, BadFileError{"data chunk before fmt chunk"}
+			}
+
+			if isRF64 && size == rf64Size && ds64DataSize >= 0 {
+				size = ds64DataSize
+			} else if size == rf64Size {
+				// Written as a stream of unknown length.
+				size = -1
+			}
+
+			h.dataOffset = pos
+			h.dataSize = size
+			if size >= 0 {
+				h.Frames = size / int64(h.Channels*h.Format.Size())
+			} else {
+				h.Frames = -1
+			}
+
+			if !seekable || size < 0 {
+				break
+			}
+
+			seeker := r.(io.Seeker)
+			pos, err = seeker.Seek(size+size%2, io.SeekCurrent)
+			if err != nil {
+				return h, err
+			}
+			continue
+		}
+
+		var body []byte
+		body, err = readChunk(r, size+size%2)
+		if err != nil {
+			// Tolerate a truncated trailing chunk.
+			if h.dataOffset != 0 {
+				break
+			}
+			return h, err
+		}
+		pos += int64(len(body))
+		body = body[:size]
+
+		switch id {
+		case "ds64":
+			if len(body) < 24 {
+				return h, BadFileError{"short ds64 chunk"}
+			}
+			ds64DataSize = int64(binary.LittleEndian.Uint64(body[8:16]))
+
+		case "fmt ":
+			err = h.parseFormat(body)
+			if err != nil {
+				return h, err
+			}
+			foundFormat = true
+
+		case "LIST":
+			if len(body) < 4 {
+				continue
+			}
+			parseList(string(body[0:4]), body[4:], &h, labels)
+
+		case "cue ":
+			h.Cues = parseCues(body)
+
+		case "smpl":
+			h.Metadata.Loops = parseSampler(body)
+
+		case "acid":
+			parseAcid(body, &h.Metadata)
+
+		case "bext":
+			h.Metadata.Broadcast = parseBroadcast(body)
+
+		case keyChunkID:
+			key = body
+		}
+	}
+
+	if h.dataOffset == 0 {
+		return h, BadFileError{"no data chunk"}
+	}
+
+	for i, cue := range h.Cues {
+		h.Cues[i].Label = labels[cue.ID]
+	}
+	h.gatherMetadata(key)
+
+	return h, nil
+}
+
+func (h *Header) parseFormat(body []byte) (err error) {
+	if len(body) < 16 {
+		return BadFileError{"short fmt chunk"}
+	}
+
+	tag := binary.LittleEndian.Uint16(body[0:2])
+	h.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+	h.SampleRate = float64(binary.LittleEndian.Uint32(body[4:8]))
+	blockAlign := int(binary.LittleEndian.Uint16(body[12:14]))
+	bits := int(binary.LittleEndian.Uint16(body[14:16]))
+
+	if tag == formatExtensible {
+		if len(body) < 40 {
+			return BadFileError{"short extensible fmt chunk"}
+		}
+		h.ChannelMask = binary.LittleEndian.Uint32(body[20:24])
+		// The sub-format GUID begins with the format tag.
+		tag = binary.LittleEndian.Uint16(body[24:26])
+	}
+
+	if h.Channels == 0 {
+		return BadFileError{"no channels"}
+	}
+
+	// Samples are stored in whole bytes.
+	container := blockAlign / h.Channels * 8
+	if container == 0 {
+		container = (bits + 7) / 8 * 8
+	}
+
+	switch {
+	case tag == formatPCM && container == 8:
+		h.Format = soundio.Uint8
+	case tag == formatPCM && container == 16:
+		h.Format = soundio.Int16
+	case tag == formatPCM && container == 24:
+		h.Format = soundio.Int24
+	case tag == formatPCM && container == 32:
+		h.Format = soundio.Int32
+	case tag == formatFloat && container == 32:
+		h.Format = soundio.Float32
+	case tag == formatFloat && container == 64:
+		h.Format = soundio.Float64
+	default:
+		return BadFileError{fmt.Sprintf("unsupported format (tag %#x, %d bits)", tag, container)}
+	}
+
+	return nil
+}
+
+func parseList(listType string, body []byte, h *Header, labels map[uint32]string) {
+	for len(body) >= 8 {
+		id := string(body[0:4])
+		size := int(binary.LittleEndian.Uint32(body[4:8]))
+		body = body[8:]
+		if size > len(body) {
+			return
+		}
+		data := body[:size]
+
+		switch {
+		case listType == "INFO":
+			if h.Info == nil {
+				h.Info = make(map[string]string)
+			}
+			h.Info[id] = cString(data)
+
+		case listType == "adtl" && id == "labl" && size >= 4:
+			labels[binary.LittleEndian.Uint32(data[0:4])] = cString(data[4:])
+		}
+
+		if size+size%2 > len(body) {
+			return
+		}
+		body = body[size+size%2:]
+	}
+}
+
+// Read a chunk body of 'size' bytes. The buffer grows as the data arrives,
+// so a corrupt size in a truncated file cannot cause a huge allocation.
+func readChunk(r io.Reader, size int64) (body []byte, err error) {
+	buf := new(bytes.Buffer)
+	_, err = io.CopyN(buf, r, size)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func parseCues(body []byte) (cues []Cue) {
+	if len(body) < 4 {
+		return nil
+	}
+
+	n := int(binary.LittleEndian.Uint32(body[0:4]))
+	body = body[4:]
+	for i := 0; i < n && len(body) >= 24; i++ {
+		cues = append(cues, Cue{
+			ID:       binary.LittleEndian.Uint32(body[0:4]),
+			Position: binary.LittleEndian.Uint32(body[20:24]),
+		})
+		body = body[24:]
+	}
+
+	return cues
+}
+
+// String up to the first NUL byte.
+func cString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
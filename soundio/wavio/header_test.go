@@ -0,0 +1,74 @@
+package wavio
+
+import (
+	"bytes"
+	"github.com/kierdavis/gosound/soundio"
+	"runtime"
+	"testing"
+)
+
+// A RIFF WAVE file made of 'chunks', which are written as they are.
+func riffFile(chunks ...[]byte) []byte {
+	body := new(bytes.Buffer)
+	body.WriteString("WAVE")
+	for _, chunk := range chunks {
+		body.Write(chunk)
+	}
+
+	buf := new(bytes.Buffer)
+	writeChunk(buf, "RIFF", body.Bytes())
+	return buf.Bytes()
+}
+
+func chunk(id string, body []byte) []byte {
+	buf := new(bytes.Buffer)
+	writeChunk(buf, id, body)
+	return buf.Bytes()
+}
+
+// The chunks that must come first in a file of 4 mono 16-bit frames.
+func minimalChunks() [][]byte {
+	return [][]byte{
+		chunk("fmt ", formatChunk(soundio.Int16, 1, 44100, 0)),
+		chunk("data", make([]byte, 8)),
+	}
+}
+
+// An odd-sized final subchunk of a LIST chunk, missing its pad byte.
+func TestParseListMissingPad(t *testing.T) {
+	list := new(bytes.Buffer)
+	list.WriteString("INFO")
+	writeChunk(list, "INAM", []byte("Tune\x00"))
+	writeChunk(list, "IART", []byte("abc"))
+	body := list.Bytes()[:list.Len()-1]
+
+	h, err := readHeader(bytes.NewReader(riffFile(append(minimalChunks(), chunk("LIST", body))...)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Info["INAM"] != "Tune" || h.Info["IART"] != "abc" {
+		t.Errorf("got tags %v", h.Info)
+	}
+}
+
+// A truncated trailing chunk declaring a huge size must neither fail nor
+// allocate its declared size.
+func TestTruncatedChunk(t *testing.T) {
+	truncated := []byte("junk\xf0\xff\xff\xffsome data")
+	file := riffFile(append(minimalChunks(), truncated)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	h, err := readHeader(bytes.NewReader(file), true)
+	runtime.ReadMemStats(&after)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames != 4 {
+		t.Errorf("got %d frames, want 4", h.Frames)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes", n)
+	}
+}
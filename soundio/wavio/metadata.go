@@ -0,0 +1,215 @@
+package wavio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/soundio"
+	"math"
+)
+
+// Sampler loop types of the smpl chunk, indexed by soundio.LoopMode.
+var loopTypes = []uint32{0, 1, 2}
+
+// Flag in the acid chunk indicating that the root note is valid.
+const acidRootNote = 0x02
+
+// Size of the fixed part of a bext chunk.
+const bextSize = 602
+
+// ID of a gosound-private chunk holding the key as text, in the form written
+// by soundio.FormatKey. Other software ignores it; the acid chunk only records
+// the root note.
+const keyChunkID = "gkey"
+
+// Fill in h.Metadata from the INFO tags and cue points, which must already
+// have been parsed. 'key' is the body of the private key chunk, if any, which
+// is preferred over the root note of the acid chunk.
+func (h *Header) gatherMetadata(key []byte) {
+	h.Metadata.Title = h.Info["INAM"]
+	h.Metadata.Artist = h.Info["IART"]
+	h.Metadata.Comment = h.Info["ICMT"]
+
+	if key != nil {
+		if k, err := soundio.ParseKey(string(key)); err == nil {
+			h.Metadata.Key = &k
+		}
+	}
+
+	for _, cue := range h.Cues {
+		h.Metadata.Markers = append(h.Metadata.Markers, soundio.Marker{
+			Position: int64(cue.Position),
+			Label:    cue.Label,
+		})
+	}
+}
+
+// Parse the loops of a smpl chunk. Loop ends are inclusive in the chunk.
+func parseSampler(body []byte) (loops []soundio.Loop) {
+	if len(body) < 36 {
+		return nil
+	}
+
+	n := int(binary.LittleEndian.Uint32(body[28:32]))
+	body = body[36:]
+	for i := 0; i < n && len(body) >= 24; i++ {
+		loop := soundio.Loop{
+			Start: int64(binary.LittleEndian.Uint32(body[8:12])),
+			End:   int64(binary.LittleEndian.Uint32(body[12:16])) + 1,
+		}
+		for mode, t := range loopTypes {
+			if binary.LittleEndian.Uint32(body[4:8]) == t {
+				loop.Mode = soundio.LoopMode(mode)
+			}
+		}
+		loops = append(loops, loop)
+		body = body[24:]
+	}
+
+	return loops
+}
+
+// Parse the tempo and root note of an acid chunk.
+func parseAcid(body []byte, md *soundio.Metadata) {
+	if len(body) < 24 {
+		return
+	}
+
+	flags := binary.LittleEndian.Uint32(body[0:4])
+	if flags&acidRootNote != 0 {
+		root := binary.LittleEndian.Uint16(body[4:6])
+		md.Key = &music.Scale{
+			Root: music.MakeNote(music.NoteLetter(root%12), 4),
+		}
+	}
+
+	md.BPM = float64(math.Float32frombits(binary.LittleEndian.Uint32(body[20:24])))
+}
+
+func parseBroadcast(body []byte) *soundio.Broadcast {
+	if len(body) < bextSize {
+		return nil
+	}
+
+	b := &soundio.Broadcast{
+		Description:         cString(body[0:256]),
+		Originator:          cString(body[256:288]),
+		OriginatorReference: cString(body[288:320]),
+		OriginationDate:     cString(body[320:330]),
+		OriginationTime:     cString(body[330:338]),
+		TimeReference:       binary.LittleEndian.Uint64(body[338:346]),
+		CodingHistory:       cString(body[bextSize:]),
+	}
+	copy(b.UMID[:], body[348:412])
+	return b
+}
+
+// Add the fields of 'md' that are stored as INFO tags and cue points to those
+// given explicitly, which take precedence.
+func mergeMetadata(info map[string]string, cues []Cue, md soundio.Metadata) (map[string]string, []Cue) {
+	tags := map[string]string{
+		"INAM": md.Title,
+		"IART": md.Artist,
+		"ICMT": md.Comment,
+	}
+
+	merged := make(map[string]string)
+	for id, value := range tags {
+		if value != "" {
+			merged[id] = value
+		}
+	}
+	for id, value := range info {
+		merged[id] = value
+	}
+
+	var nextID uint32 = 1
+	for _, cue := range cues {
+		if cue.ID >= nextID {
+			nextID = cue.ID + 1
+		}
+	}
+
+	cues = append([]Cue(nil), cues...)
+	for _, marker := range md.Markers {
+		cues = append(cues, Cue{
+			ID:       nextID,
+			Position: uint32(marker.Position),
+			Label:    marker.Label,
+		})
+		nextID++
+	}
+
+	return merged, cues
+}
+
+// Body of a smpl chunk holding the loops of 'md'.
+func samplerChunk(md soundio.Metadata, sampleRate float64) []byte {
+	buf := new(bytes.Buffer)
+	writeUint32(buf, 0) // manufacturer
+	writeUint32(buf, 0) // product
+	writeUint32(buf, uint32(1e9/sampleRate+0.5))
+	writeUint32(buf, 60) // MIDI unity note
+	writeUint32(buf, 0)  // pitch fraction
+	writeUint32(buf, 0)  // SMPTE format
+	writeUint32(buf, 0)  // SMPTE offset
+	writeUint32(buf, uint32(len(md.Loops)))
+	writeUint32(buf, 0) // sampler data size
+
+	for i, loop := range md.Loops {
+		loopType := loopTypes[0]
+		if int(loop.Mode) < len(loopTypes) {
+			loopType = loopTypes[loop.Mode]
+		}
+
+		writeUint32(buf, uint32(i))
+		writeUint32(buf, loopType)
+		writeUint32(buf, uint32(loop.Start))
+		writeUint32(buf, uint32(loop.End-1))
+		writeUint32(buf, 0) // fraction
+		writeUint32(buf, 0) // play count (infinite)
+	}
+
+	return buf.Bytes()
+}
+
+// Body of an acid chunk holding the tempo and root note of 'md'. The number
+// of beats is only known if 'frames' is not negative.
+func acidChunk(md soundio.Metadata, sampleRate float64, frames int64) []byte {
+	var flags uint32
+	var root uint16
+	if md.Key != nil {
+		flags |= acidRootNote
+		root = uint16(48 + md.Key.Root.Letter())
+	}
+
+	var beats uint32
+	if frames >= 0 {
+		beats = uint32(float64(frames)/sampleRate*md.BPM/60 + 0.5)
+	}
+
+	buf := new(bytes.Buffer)
+	writeUint32(buf, flags)
+	writeUint16(buf, root)
+	writeUint16(buf, 0x8000)
+	writeUint32(buf, 0)
+	writeUint32(buf, beats)
+	writeUint16(buf, 4) // meter denominator
+	writeUint16(buf, 4) // meter numerator
+	writeUint32(buf, math.Float32bits(float32(md.BPM)))
+	return buf.Bytes()
+}
+
+// Body of a version 1 bext chunk.
+func broadcastChunk(b *soundio.Broadcast) []byte {
+	body := make([]byte, bextSize, bextSize+len(b.CodingHistory))
+	copy(body[0:256], b.Description)
+	copy(body[256:288], b.Originator)
+	copy(body[288:320], b.OriginatorReference)
+	copy(body[320:330], b.OriginationDate)
+	copy(body[330:338], b.OriginationTime)
+	binary.LittleEndian.PutUint64(body[338:346], b.TimeReference)
+	binary.LittleEndian.PutUint16(body[346:348], 1)
+	copy(body[348:412], b.UMID[:])
+	return append(body, b.CodingHistory...)
+}
@@ -0,0 +1,112 @@
+package wavio
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+)
+
+// StreamInput reads a WAV stream from an io.Reader, such as a pipe, that
+// cannot seek. Chunks after the sample data are ignored, and a data chunk of
+// unknown length is read until the end of the stream.
+type StreamInput struct {
+	Reader     io.Reader
+	BufferSize int
+}
+
+func (si StreamInput) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	r := bufio.NewReader(si.Reader)
+	h, err := readHeader(r, false)
+	if err != nil {
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	channels = make([]chan float64, h.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		_, err := readSamples(r, h, channels, si.BufferSize)
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return h.SampleRate, channels, errChan
+}
+
+// StreamOutput writes a WAV stream to an io.Writer, such as a pipe, that
+// cannot seek back to fill in the chunk sizes. The sizes are written as
+// 0xFFFFFFFF, which readers such as sox and ffmpeg take to mean "until the end
+// of the stream". Metadata is written before the sample data.
+type StreamOutput struct {
+	Writer     io.Writer
+	Format     soundio.SampleFormat
+	BufferSize int
+
+	// Dither applied when converting to an integer format.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	// Speaker positions of the channels, as used by WAVE_FORMAT_EXTENSIBLE.
+	// If zero, no positions are specified.
+	ChannelMask uint32
+
+	// Tags to write in a LIST/INFO chunk, keyed by chunk ID.
+	Info map[string]string
+
+	// Cue points to write, with their labels.
+	Cues []Cue
+
+	// Metadata to write, as for Output. The number of beats in the acid
+	// chunk is left as zero, since the length is not known in advance.
+	Metadata soundio.Metadata
+}
+
+func (so StreamOutput) Write(sampleRate float64, channels []chan float64) (err error) {
+	// 8-bit WAV data is always unsigned.
+	if so.Format == soundio.Int8 {
+		so.Format = soundio.Uint8
+	}
+
+	header := new(bytes.Buffer)
+	header.WriteString("RIFF")
+	writeUint32(header, rf64Size)
+	header.WriteString("WAVE")
+	writeChunk(header, "fmt ", formatChunk(so.Format, len(channels), sampleRate, so.ChannelMask))
+	if so.Metadata.Broadcast != nil {
+		writeChunk(header, "bext", broadcastChunk(so.Metadata.Broadcast))
+	}
+	writeMetadata(header, so.Info, so.Cues, so.Metadata, sampleRate, -1)
+	header.WriteString("data")
+	writeUint32(header, rf64Size)
+
+	w := bufio.NewWriter(so.Writer)
+	_, err = w.Write(header.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = writeSamples(w, so.Format, so.Dither, so.LengthPolicy, channels, so.BufferSize)
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,446 @@
+// Package wavio reads and writes WAV files without cgo.
+//
+// PCM (8, 16, 24 and 32 bit) and IEEE float (32 and 64 bit) data are
+// supported, in plain, WAVE_FORMAT_EXTENSIBLE and RF64 files. LIST/INFO tags
+// and cue points with labels are preserved by ReadHeader and Output, along
+// with sampler loops, ACID tempo and the BWF bext chunk as soundio.Metadata.
+package wavio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"github.com/kierdavis/gosound/soundio"
+	"io"
+	"os"
+	"sort"
+)
+
+type Input struct {
+	Filename   string
+	BufferSize int
+
+	// Part of the file to read. The zero Region reads the whole file once.
+	Region soundio.Region
+}
+
+// Info reads the format and length of the file without streaming it.
+func (si Input) Info() (info soundio.FileInfo, err error) {
+	h, err := ReadHeader(si.Filename)
+	if err != nil {
+		return info, err
+	}
+
+	return soundio.FileInfo{
+		SampleRate: h.SampleRate,
+		Channels:   h.Channels,
+		Frames:     h.Frames,
+		Format:     "WAV " + h.Format.String(),
+	}, nil
+}
+
+// Metadata reads the metadata of the file without streaming it.
+func (si Input) Metadata() (md soundio.Metadata, err error) {
+	h, err := ReadHeader(si.Filename)
+	return h.Metadata, err
+}
+
+func (si Input) Read() (sampleRate float64, channels []chan float64, errChan chan error) {
+	errChan = make(chan error, 2)
+
+	f, err := os.Open(si.Filename)
+	if err != nil {
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	h, err := readHeader(f, true)
+	if err != nil {
+		f.Close()
+		errChan <- err
+		return 0, nil, errChan
+	}
+
+	channels = make([]chan float64, h.Channels)
+	for i, _ := range channels {
+		channels[i] = make(chan float64, si.BufferSize)
+	}
+
+	go func() {
+		defer func() {
+			err2 := f.Close()
+			if err2 != nil {
+				errChan <- err2
+			}
+
+			close(errChan)
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		frameSize := int64(h.Channels * h.Format.Size())
+		start, end := si.Region.Bounds(h.Frames)
+		if start == end {
+			return
+		}
+
+		region := h
+		region.dataSize = -1
+		if end >= 0 {
+			region.dataSize = (end - start) * frameSize
+		}
+
+		err := si.Region.Repeat(func() (frames int64, err error) {
+			_, err = f.Seek(h.dataOffset+start*frameSize, io.SeekStart)
+			if err != nil {
+				return 0, err
+			}
+			return readSamples(f, region, channels, si.BufferSize)
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return h.SampleRate, channels, errChan
+}
+
+// Read the sample data described by 'h' from 'r' and distribute it to
+// 'channels', returning the number of frames read. A negative data size means
+// read until EOF.
+func readSamples(r io.Reader, h Header, channels []chan float64, bufferSize int) (frames int64, err error) {
+	if bufferSize <= 0 {
+		bufferSize = soundio.DefaultBufferSize
+	}
+
+	sampleSize := h.Format.Size()
+	frameSize := sampleSize * h.Channels
+	byteBuffer := make([]byte, frameSize*bufferSize)
+	buffer := make([]float64, h.Channels*bufferSize)
+	remaining := h.dataSize
+
+	for remaining != 0 {
+		chunk := byteBuffer
+		if remaining > 0 && int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// Tolerate truncated files and streams of unknown length.
+			err = nil
+			remaining = 0
+		} else if err != nil {
+			return frames, err
+		}
+
+		if remaining > 0 {
+			remaining -= int64(n)
+		}
+
+		// Discard any partial frame at the end.
+		n -= n % frameSize
+		for i := 0; i < n/sampleSize; i++ {
+			buffer[i] = h.Format.Decode(byteBuffer[i*sampleSize:], binary.LittleEndian)
+		}
+		soundio.Deinterlace(buffer[:n/sampleSize], channels)
+		frames += int64(n / frameSize)
+	}
+
+	return frames, nil
+}
+
+type Output struct {
+	Filename   string
+	Format     soundio.SampleFormat
+	BufferSize int
+
+	// Dither applied when converting to an integer format.
+	Dither soundio.Dither
+
+	// How channels of different lengths are handled. The zero value pads
+	// shorter channels with silence; with RequireEqualLength, Write returns
+	// soundio.ErrLengthMismatch if they differ.
+	LengthPolicy soundio.LengthPolicy
+
+	// Speaker positions of the channels, as used by WAVE_FORMAT_EXTENSIBLE.
+	// If zero, no positions are specified.
+	ChannelMask uint32
+
+	// Tags to write in a LIST/INFO chunk, keyed by chunk ID.
+	Info map[string]string
+
+	// Cue points to write, with their labels.
+	Cues []Cue
+
+	// Metadata to write. The title, artist and comment are written as INFO
+	// tags and the markers as cue points, in addition to Info and Cues.
+	// Loops are written in a smpl chunk, the tempo and the root note of the
+	// key in an acid chunk, and the broadcast extension in a bext chunk. The
+	// full key is also written in a gosound-private chunk, which only this
+	// package reads.
+	Metadata soundio.Metadata
+}
+
+func (so Output) Write(sampleRate float64, channels []chan float64) (err error) {
+	// 8-bit WAV data is always unsigned.
+	if so.Format == soundio.Int8 {
+		so.Format = soundio.Uint8
+	}
+
+	f, err := os.Create(so.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	fmtChunk := formatChunk(so.Format, len(channels), sampleRate, so.ChannelMask)
+
+	// Reserve room for a ds64 chunk as a JUNK chunk, in case the file grows
+	// beyond 4 GiB and must become RF64.
+	header := new(bytes.Buffer)
+	header.WriteString("RIFF")
+	writeUint32(header, 0)
+	header.WriteString("WAVE")
+	writeChunk(header, "JUNK", make([]byte, 28))
+	writeChunk(header, "fmt ", fmtChunk)
+	if so.Metadata.Broadcast != nil {
+		writeChunk(header, "bext", broadcastChunk(so.Metadata.Broadcast))
+	}
+	header.WriteString("data")
+	writeUint32(header, 0)
+
+	_, err = w.Write(header.Bytes())
+	if err != nil {
+		return err
+	}
+
+	dataOffset := int64(header.Len())
+	dataSize, err := writeSamples(w, so.Format, so.Dither, so.LengthPolicy, channels, so.BufferSize)
+	if err != nil {
+		return err
+	}
+
+	if dataSize%2 == 1 {
+		w.WriteByte(0)
+	}
+
+	frames := dataSize / int64(so.Format.Size()*len(channels))
+	trailer := new(bytes.Buffer)
+	writeMetadata(trailer, so.Info, so.Cues, so.Metadata, sampleRate, frames)
+	_, err = w.Write(trailer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	fileSize := dataOffset + dataSize + dataSize%2 + int64(trailer.Len())
+	return patchSizes(f, fileSize, dataOffset, dataSize, frames)
+}
+
+// Write the samples of 'channels' to 'w' in the given format, returning the
+// number of bytes written.
+func writeSamples(w io.Writer, format soundio.SampleFormat, dither soundio.Dither, policy soundio.LengthPolicy, channels []chan float64, bufferSize int) (dataSize int64, err error) {
+	sampleSize := format.Size()
+	q := soundio.NewQuantizer(format, dither, len(channels))
+
+	il := soundio.NewInterlacer(channels, bufferSize, policy)
+	byteBuffer := make([]byte, len(channels)*il.BufferSize*sampleSize)
+	for buffer := il.Next(); buffer != nil; buffer = il.Next() {
+		q.Encode(byteBuffer, buffer, binary.LittleEndian)
+
+		_, err = w.Write(byteBuffer[:len(buffer)*sampleSize])
+		if err != nil {
+			return dataSize, err
+		}
+		dataSize += int64(len(buffer) * sampleSize)
+		il.Release(buffer)
+	}
+
+	return dataSize, il.Err()
+}
+
+// Fill in the chunk sizes once the length of the data is known, converting to
+// RF64 if they do not fit in 32 bits.
+func patchSizes(f io.WriteSeeker, fileSize, dataOffset, dataSize, frames int64) (err error) {
+	riffSize := fileSize - 8
+
+	if riffSize <= 0xFFFFFFFF && dataSize < rf64Size {
+		err = writeAt(f, 4, uint32Bytes(uint32(riffSize)))
+		if err != nil {
+			return err
+		}
+		return writeAt(f, dataOffset-4, uint32Bytes(uint32(dataSize)))
+	}
+
+	err = writeAt(f, 0, []byte("RF64"))
+	if err != nil {
+		return err
+	}
+	err = writeAt(f, 4, uint32Bytes(rf64Size))
+	if err != nil {
+		return err
+	}
+
+	ds64 := new(bytes.Buffer)
+	ds64.WriteString("ds64")
+	writeUint32(ds64, 28)
+	writeUint64(ds64, uint64(riffSize))
+	writeUint64(ds64, uint64(dataSize))
+	writeUint64(ds64, uint64(frames))
+	writeUint32(ds64, 0) // no table entries
+	err = writeAt(f, 12, ds64.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return writeAt(f, dataOffset-4, uint32Bytes(rf64Size))
+}
+
+func writeAt(f io.WriteSeeker, offset int64, data []byte) (err error) {
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Body of the fmt chunk. The extensible format is used when required by the
+// channel count or sample size, or to carry a channel mask.
+func formatChunk(format soundio.SampleFormat, numChannels int, sampleRate float64, channelMask uint32) (body []byte) {
+	buf := new(bytes.Buffer)
+
+	tag := uint16(formatPCM)
+	if format.IsFloat() {
+		tag = formatFloat
+	}
+
+	extensible := numChannels > 2 || channelMask != 0 || (!format.IsFloat() && format.Bits() > 16)
+
+	blockAlign := numChannels * format.Size()
+	if extensible {
+		writeUint16(buf, formatExtensible)
+	} else {
+		writeUint16(buf, tag)
+	}
+	writeUint16(buf, uint16(numChannels))
+	writeUint32(buf, uint32(sampleRate))
+	writeUint32(buf, uint32(int(sampleRate)*blockAlign))
+	writeUint16(buf, uint16(blockAlign))
+	writeUint16(buf, uint16(format.Bits()))
+
+	if extensible {
+		writeUint16(buf, 22)
+		writeUint16(buf, uint16(format.Bits())) // valid bits per sample
+		writeUint32(buf, channelMask)
+		// KSDATAFORMAT_SUBTYPE_PCM or _IEEE_FLOAT
+		writeUint16(buf, tag)
+		buf.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71})
+	} else if format.IsFloat() {
+		writeUint16(buf, 0)
+	}
+
+	return buf.Bytes()
+}
+
+// Write the LIST/INFO, cue, LIST/adtl, smpl, acid and key chunks, adding the
+// fields of 'md' to the explicit tags and cues. 'frames' is -1 if unknown.
+func writeMetadata(buf *bytes.Buffer, info map[string]string, cues []Cue, md soundio.Metadata, sampleRate float64, frames int64) {
+	info, cues = mergeMetadata(info, cues, md)
+
+	if len(info) > 0 {
+		ids := make([]string, 0, len(info))
+		for id := range info {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		list := new(bytes.Buffer)
+		list.WriteString("INFO")
+		for _, id := range ids {
+			writeChunk(list, id, append([]byte(info[id]), 0))
+		}
+		writeChunk(buf, "LIST", list.Bytes())
+	}
+
+	if len(cues) > 0 {
+		cueChunk := new(bytes.Buffer)
+		writeUint32(cueChunk, uint32(len(cues)))
+		for _, cue := range cues {
+			writeUint32(cueChunk, cue.ID)
+			writeUint32(cueChunk, cue.Position)
+			cueChunk.WriteString("data")
+			writeUint32(cueChunk, 0) // chunk start
+			writeUint32(cueChunk, 0) // block start
+			writeUint32(cueChunk, cue.Position)
+		}
+		writeChunk(buf, "cue ", cueChunk.Bytes())
+
+		adtl := new(bytes.Buffer)
+		adtl.WriteString("adtl")
+		for _, cue := range cues {
+			if cue.Label == "" {
+				continue
+			}
+			labl := new(bytes.Buffer)
+			writeUint32(labl, cue.ID)
+			labl.WriteString(cue.Label)
+			labl.WriteByte(0)
+			writeChunk(adtl, "labl", labl.Bytes())
+		}
+		if adtl.Len() > 4 {
+			writeChunk(buf, "LIST", adtl.Bytes())
+		}
+	}
+
+	if len(md.Loops) > 0 {
+		writeChunk(buf, "smpl", samplerChunk(md, sampleRate))
+	}
+
+	if md.BPM > 0 || md.Key != nil {
+		writeChunk(buf, "acid", acidChunk(md, sampleRate, frames))
+	}
+
+	if md.Key != nil {
+		writeChunk(buf, keyChunkID, []byte(soundio.FormatKey(*md.Key)))
+	}
+}
+
+func writeChunk(buf *bytes.Buffer, id string, body []byte) {
+	buf.WriteString(id)
+	writeUint32(buf, uint32(len(body)))
+	buf.Write(body)
+	if len(body)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func writeUint16(buf *bytes.Buffer, x uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], x)
+	buf.Write(b[:])
+}
+
+func writeUint32(buf *bytes.Buffer, x uint32) {
+	buf.Write(uint32Bytes(x))
+}
+
+func writeUint64(buf *bytes.Buffer, x uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], x)
+	buf.Write(b[:])
+}
+
+func uint32Bytes(x uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], x)
+	return b[:]
+}
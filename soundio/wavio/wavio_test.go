@@ -0,0 +1,326 @@
+package wavio
+
+import (
+	"bytes"
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/soundio"
+	"github.com/kierdavis/gosound/soundio/memio"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Two channels of tones at different frequencies.
+func tones(frames int) memio.Input {
+	channels := [][]float64{make([]float64, frames), make([]float64, frames)}
+	for i := 0; i < frames; i++ {
+		channels[0][i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		channels[1][i] = 0.25 * math.Sin(2*math.Pi*1000*float64(i)/44100)
+	}
+	return memio.Input{SampleRate: 44100, Channels: channels, BufferSize: 64}
+}
+
+func checkSamples(t *testing.T, name string, got *memio.Output, want memio.Input, tolerance float64) {
+	if got.SampleRate != want.SampleRate {
+		t.Errorf("%s: got sample rate %v, want %v", name, got.SampleRate, want.SampleRate)
+	}
+	if len(got.Channels) != len(want.Channels) {
+		t.Fatalf("%s: got %d channels, want %d", name, len(got.Channels), len(want.Channels))
+	}
+	for c, buffer := range want.Channels {
+		if len(got.Channels[c]) != len(buffer) {
+			t.Fatalf("%s: channel %d has %d samples, want %d", name, c, len(got.Channels[c]), len(buffer))
+		}
+		for i, x := range buffer {
+			if math.Abs(got.Channels[c][i]-x) > tolerance {
+				t.Errorf("%s: channel %d sample %d is %v, want %v", name, c, i, got.Channels[c][i], x)
+				break
+			}
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wavio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// Largest error from quantising to 'format'.
+func tolerance(format soundio.SampleFormat) float64 {
+	if format.IsFloat() {
+		return 1e-7
+	}
+	return 1 / math.Pow(2, float64(format.Bits()-1))
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	for _, format := range []soundio.SampleFormat{soundio.Int8, soundio.Int16, soundio.Int24, soundio.Int32, soundio.Float32, soundio.Float64} {
+		in := tones(1001)
+		err := soundio.Copy(Output{Filename: filename, Format: format, BufferSize: 64}, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := new(memio.Output)
+		err = soundio.Copy(out, Input{Filename: filename, BufferSize: 64})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSamples(t, format.String(), out, in, tolerance(format))
+	}
+}
+
+// An empty data chunk followed by metadata chunks holds no frames, rather than
+// a stream of unknown length.
+func TestEmptyRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	md := soundio.Metadata{Title: "Title", Artist: "Artist"}
+	err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16, BufferSize: 64, Metadata: md}, tones(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Input{Filename: filename}.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Frames != 0 {
+		t.Errorf("got %d frames in the header, want 0", info.Frames)
+	}
+
+	out := new(memio.Output)
+	err = soundio.Copy(out, Input{Filename: filename, BufferSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSamples(t, "empty", out, tones(0), 0)
+}
+
+// Inputs and outputs left with a zero buffer size must still transfer every
+// frame.
+func TestZeroBufferSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	in := tones(1001)
+	in.BufferSize = 0
+	err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(memio.Output)
+	done := make(chan error)
+	go func() {
+		done <- soundio.Copy(out, Input{Filename: filename})
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reading with a zero buffer size did not finish")
+	}
+	checkSamples(t, "zero buffer size", out, in, tolerance(soundio.Int16))
+}
+
+// An Output with no Format set writes 16-bit samples.
+func TestDefaultFormat(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	if err := soundio.Copy(Output{Filename: filename, BufferSize: 64}, tones(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Format != soundio.Int16 {
+		t.Errorf("got format %v, want int16", h.Format)
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	in := tones(1001)
+	err := soundio.Copy(StreamOutput{Writer: buf, Format: soundio.Int16, BufferSize: 64}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(memio.Output)
+	err = soundio.Copy(out, StreamInput{Reader: buf, BufferSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSamples(t, "stream", out, in, tolerance(soundio.Int16))
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	key := music.Scale{Root: music.MakeNote(music.FSharp, 4), Intervals: music.Minor}
+	md := soundio.Metadata{
+		Title:   "Title",
+		Artist:  "Artist",
+		Comment: "Comment",
+		BPM:     120,
+		Key:     &key,
+		Loops:   []soundio.Loop{{Start: 100, End: 900, Mode: soundio.LoopPingPong}},
+		Markers: []soundio.Marker{{Position: 10, Label: "one"}, {Position: 500, Label: "two"}},
+		Broadcast: &soundio.Broadcast{
+			Description:     "Description",
+			OriginationDate: "2026-10-19",
+			TimeReference:   12345,
+		},
+	}
+
+	err := soundio.Copy(Output{Filename: filename, Format: soundio.Int16, BufferSize: 64, Metadata: md}, tones(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Input{Filename: filename}.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != md.Title || got.Artist != md.Artist || got.Comment != md.Comment {
+		t.Errorf("got tags %q, %q, %q", got.Title, got.Artist, got.Comment)
+	}
+	if got.BPM != md.BPM {
+		t.Errorf("got tempo %v, want %v", got.BPM, md.BPM)
+	}
+	if got.Key == nil || got.Key.Root.Letter() != music.FSharp || got.Key.Mode() != "minor" {
+		t.Errorf("got key %v, want F# minor", got.Key)
+	}
+	if len(got.Loops) != 1 || got.Loops[0] != md.Loops[0] {
+		t.Errorf("got loops %v, want %v", got.Loops, md.Loops)
+	}
+	if len(got.Markers) != 2 || got.Markers[0] != md.Markers[0] || got.Markers[1] != md.Markers[1] {
+		t.Errorf("got markers %v, want %v", got.Markers, md.Markers)
+	}
+	if b := got.Broadcast; b == nil || b.Description != "Description" || b.OriginationDate != "2026-10-19" || b.TimeReference != 12345 {
+		t.Errorf("got broadcast extension %+v", b)
+	}
+}
+
+// IKEY holds keywords, which must be left alone rather than read as a key.
+func TestKeywordsNotKey(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	key := music.Scale{Root: music.MakeNote(music.A, 4), Intervals: music.Minor}
+	so := Output{
+		Filename:   filename,
+		Format:     soundio.Int16,
+		BufferSize: 64,
+		Info:       map[string]string{"IKEY": "Am"},
+	}
+	if err := soundio.Copy(so, tones(100)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Info["IKEY"] != "Am" || h.Metadata.Key != nil {
+		t.Errorf("got keywords %q and key %v, want \"Am\" and no key", h.Info["IKEY"], h.Metadata.Key)
+	}
+
+	so.Info = nil
+	so.Metadata.Key = &key
+	if err := soundio.Copy(so, tones(100)); err != nil {
+		t.Fatal(err)
+	}
+	h, err = ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.Info["IKEY"]; ok {
+		t.Errorf("key written as keywords %q", h.Info["IKEY"])
+	}
+}
+
+func TestLengthPolicy(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	in := memio.Input{SampleRate: 44100, Channels: [][]float64{make([]float64, 100), make([]float64, 60)}}
+
+	so := Output{Filename: filename, Format: soundio.Int16, BufferSize: 16, LengthPolicy: soundio.RequireEqualLength}
+	if err := soundio.Copy(so, in); err != soundio.ErrLengthMismatch {
+		t.Errorf("got error %v, want ErrLengthMismatch", err)
+	}
+
+	so.LengthPolicy = soundio.TruncateToShortest
+	if err := soundio.Copy(so, in); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames != 60 {
+		t.Errorf("got %d frames, want 60", h.Frames)
+	}
+}
+
+// Looping a region that starts beyond the end of a file of unknown length
+// must end rather than spin.
+func TestLoopPastEnd(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.wav")
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = soundio.Copy(StreamOutput{Writer: f, Format: soundio.Int16, BufferSize: 64}, tones(100))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	out := new(memio.Output)
+	go func() {
+		done <- soundio.Copy(out, Input{Filename: filename, BufferSize: 64, Region: soundio.Region{Start: 1000, Loops: soundio.LoopForever}})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("looping an empty region did not end")
+	}
+	if len(out.Channels[0]) != 0 {
+		t.Errorf("got %d frames, want 0", len(out.Channels[0]))
+	}
+}
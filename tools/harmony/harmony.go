@@ -0,0 +1,82 @@
+// Command harmony prints the estimated key and chord timeline of an audio
+// file.
+//
+// Usage: harmony <input file>
+package main
+
+import (
+	"fmt"
+	"github.com/kierdavis/gosound/music"
+	"github.com/kierdavis/gosound/sound"
+	"github.com/kierdavis/gosound/sound/features"
+	"github.com/kierdavis/gosound/sound/tonal"
+	"github.com/kierdavis/gosound/soundio/sndfileio"
+	"os"
+	"time"
+)
+
+const (
+	// Chord matches weaker than this are reported as "N" (no chord).
+	MinSimilarity = 0.6
+
+	// Chords lasting fewer frames than this are merged into their
+	// predecessor.
+	MinChordFrames = 4
+)
+
+func readInputFile(filename string) (ctx sound.Context, stream chan float64) {
+	si := sndfileio.SndFileInput{
+		Filename:   filename,
+		BufferSize: 512,
+	}
+
+	sampleRate, channels, errChan := si.Read()
+	go func() {
+		err := <-errChan
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		}
+	}()
+
+	if len(channels) == 0 {
+		fmt.Fprintf(os.Stderr, "No channels!\n")
+		os.Exit(1)
+	}
+
+	ctx = sound.DefaultContext
+	ctx.SampleRate = sampleRate
+
+	// Mix down to mono.
+	stream = ctx.Add(channels...)
+	if len(channels) > 1 {
+		stream = ctx.MulInf(stream, ctx.Const(1.0/float64(len(channels))))
+	}
+
+	return ctx, stream
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <input file>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	ctx, stream := readInputFile(os.Args[1])
+	p := features.DefaultParams
+
+	var chromas [][12]float64
+	var chords []music.Chord
+	for frame := range features.Extract(ctx, stream, p) {
+		chromas = append(chromas, frame.Chroma)
+		chord, _ := tonal.RecogniseChord(frame.Chroma, MinSimilarity)
+		chords = append(chords, chord)
+	}
+
+	key, correlation := tonal.DetectKey(chromas)
+	fmt.Printf("Key: %s (correlation %.2f)\n", key, correlation)
+
+	frameDuration := time.Duration(float64(p.HopSize) / ctx.SampleRate * float64(time.Second))
+	for _, segment := range tonal.Timeline(chords, frameDuration, MinChordFrames) {
+		fmt.Printf("%8.2fs %8.2fs  %s\n", segment.Start.Seconds(), segment.End.Seconds(), segment.Chord)
+	}
+}